@@ -0,0 +1,74 @@
+// Command reconcile-leaves rebuilds leave_allocations from the leave ledger.
+//
+//	go run ./cmd/reconcile-leaves            # report duplicates and drift only
+//	go run ./cmd/reconcile-leaves -apply     # merge duplicates, migrate and rewrite drifted allocations
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/joho/godotenv"
+
+	"peoplesoft/config"
+	"peoplesoft/controllers"
+	"peoplesoft/models"
+)
+
+func main() {
+	apply := flag.Bool("apply", false, "rewrite allocations that disagree with the ledger")
+	flag.Parse()
+
+	_ = godotenv.Load()
+
+	if err := config.ConnectDatabase(); err != nil {
+		log.Fatalf("DB connection failed: %v", err)
+	}
+	if *apply {
+		if err := controllers.MergeDuplicateLeaveAllocations(); err != nil {
+			log.Fatalf("merging duplicate allocations failed: %v", err)
+		}
+		if err := config.DB.AutoMigrate(&models.LeaveAllocation{}, &models.LeaveTransaction{}); err != nil {
+			log.Fatalf("AutoMigrate failed: %v", err)
+		}
+	} else {
+		dups, err := controllers.DuplicateLeaveAllocations()
+		if err != nil {
+			log.Fatalf("listing duplicate allocations failed: %v", err)
+		}
+		for _, d := range dups {
+			log.Printf("user=%d year=%d type=%s has %d allocation rows; merging keeps total/used=%g/%g",
+				d.UserID, d.Year, d.Type, d.Rows, d.Total, d.Used)
+		}
+		if len(dups) > 0 {
+			log.Printf("⚠️ %d allocations are duplicated; re-run with -apply to merge them", len(dups))
+		}
+		if !config.DB.Migrator().HasTable(&models.LeaveTransaction{}) {
+			log.Println("⚠️ the leave ledger table does not exist yet; re-run with -apply to create it")
+			return
+		}
+	}
+
+	drifts, err := controllers.ReconcileLeaveAllocations(*apply)
+	for _, d := range drifts {
+		if d.MissingOpening {
+			log.Printf("user=%d year=%d type=%s cached total/used=%g/%g has no ledger rows (no opening balance)",
+				d.UserID, d.Year, d.Type, d.CachedTotal, d.CachedUsed)
+			continue
+		}
+		log.Printf("user=%d year=%d type=%s cached total/used=%g/%g ledger total/used=%g/%g",
+			d.UserID, d.Year, d.Type, d.CachedTotal, d.CachedUsed, d.LedgerTotal, d.LedgerUsed)
+	}
+	if err != nil {
+		log.Fatalf("reconciliation failed: %v", err)
+	}
+
+	switch {
+	case len(drifts) == 0:
+		log.Println("✅ all allocations match the ledger")
+	case *apply:
+		log.Printf("✅ rebuilt %d allocations from the ledger", len(drifts))
+	default:
+		log.Printf("⚠️ %d allocations drifted; re-run with -apply to fix", len(drifts))
+	}
+}
@@ -23,7 +23,8 @@ func ConnectDatabase() error {
 	)
 
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info), // log SQL (helps when migrate fails)
+		Logger:         logger.Default.LogMode(logger.Info), // log SQL (helps when migrate fails)
+		TranslateError: true,                                // unique violations come back as gorm.ErrDuplicatedKey
 	})
 	if err != nil {
 		return fmt.Errorf("open: %w", err)
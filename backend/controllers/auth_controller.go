@@ -1,7 +1,10 @@
 package controllers
 
 import (
+	"crypto/rand"
+	"encoding/base32"
 	"net/http"
+	"strings"
 
 	"peoplesoft/config"
 	"peoplesoft/models"
@@ -45,3 +48,75 @@ func Login(c *gin.Context) {
 	token, _ := utils.GenerateToken(user.Email, user.Role)
 	c.JSON(http.StatusOK, gin.H{"token": token, "role": user.Role, "email": user.Email})
 }
+
+// temporaryPassword returns a random password for an account created or reset
+// by HR, and its bcrypt hash.
+func temporaryPassword() (string, string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	password := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	return password, string(hash), err
+}
+
+// PUT /api/me/password
+// Body: {"current_password": "...", "new_password": "..."}
+func ChangeMyPassword(c *gin.Context) {
+	var body struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	if len(body.NewPassword) < 8 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the new password must be at least 8 characters"})
+		return
+	}
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(body.CurrentPassword)) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "bad credentials"})
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(body.NewPassword), 10)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update password"})
+		return
+	}
+	if err := config.DB.Model(&user).Update("password_hash", string(hash)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update password"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "password changed"})
+}
+
+// POST /api/users/:id/reset-password (HR only)
+// Replaces the user's password with a temporary one, returned only here.
+func ResetUserPassword(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can reset passwords"})
+		return
+	}
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	password, hash, err := temporaryPassword()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+	if err := config.DB.Model(&user).Update("password_hash", hash).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": user.ID, "email": user.Email, "temporary_password": password})
+}
@@ -184,21 +184,21 @@ func buildUserContext(userID uint, employeeID uint, role string) string {
 		}
 	}
 
-	// Get leave balances
-	var balances []models.LeaveAllocation
-	if err := config.DB.Where("user_id = ?", userID).Find(&balances).Error; err == nil {
-		fmt.Printf("[CHATBOT] Found %d leave allocations\n", len(balances))
-		if len(balances) > 0 {
-			context += "\nLeave Balances:\n"
-			for _, b := range balances {
-				remaining := b.Total - b.Used
-				context += fmt.Sprintf("- %s: %d/%d remaining\n", b.Type, remaining, b.Total)
+	// Get leave balances, projected to year-end like GET /api/leaves/balance/forecast
+	yearEnd := time.Date(time.Now().Year(), 12, 31, 0, 0, 0, 0, time.UTC)
+	if forecasts, err := forecastBalances(userID, yearEnd); err == nil {
+		fmt.Printf("[CHATBOT] Forecast %d leave balances\n", len(forecasts))
+		if len(forecasts) > 0 {
+			context += "\nLeave Balances (already-booked leave is deducted):\n"
+			for _, f := range forecasts {
+				context += fmt.Sprintf("- %s: %g remaining now (%g in upcoming approved leave, %g pending approval); projected %g by %s (+%g accruals, -%g expiring, -%g pending encashment)\n",
+					f.Type, f.Remaining, f.UpcomingApproved, f.UpcomingPending, f.Projected, yearEnd.Format("2006-01-02"), f.Accruals, f.Expiring, f.PendingEncashment)
 			}
 		} else {
 			context += "\nLeave Balances: No leave allocations found for this user\n"
 		}
 	} else {
-		fmt.Printf("[CHATBOT] Error fetching leave balances: %v\n", err)
+		fmt.Printf("[CHATBOT] Error forecasting leave balances: %v\n", err)
 	}
 
 	// Get recent leaves with ALL details
@@ -208,14 +208,20 @@ func buildUserContext(userID uint, employeeID uint, role string) string {
 		if len(leaves) > 0 {
 			context += "\nRecent Leave Requests:\n"
 			for _, l := range leaves {
-				// Calculate duration
+				// Calculate duration (charged days when recorded, calendar days otherwise)
 				duration := l.EndDate.Sub(l.StartDate).Hours()/24 + 1
+				if l.Days > 0 {
+					duration = l.Days
+				}
 
 				context += fmt.Sprintf("- Type: %s\n", l.Type)
-				context += fmt.Sprintf("  Dates: %s to %s (%d days)\n",
+				context += fmt.Sprintf("  Dates: %s to %s (%g days)\n",
 					l.StartDate.Format("2006-01-02"),
 					l.EndDate.Format("2006-01-02"),
-					int(duration))
+					duration)
+				if l.Portion != "" && l.Portion != "full" {
+					context += fmt.Sprintf("  Portion: %s\n", l.Portion)
+				}
 				context += fmt.Sprintf("  Status: %s\n", l.Status)
 				if l.Reason != "" {
 					context += fmt.Sprintf("  Reason: %s\n", l.Reason)
@@ -323,17 +329,15 @@ func buildUserContext(userID uint, employeeID uint, role string) string {
 				context += fmt.Sprintf("  Designation: %s, Location: %s\n", tm.Designation, tm.Location)
 				context += fmt.Sprintf("  Email: %s, Phone: %s\n", tmUser.Email, tm.Phone)
 
-				// Get team member's leave balances (only for direct manager)
+				// Get team member's leave balances (only for direct manager), projected like the user's own
 				if role == "manager" {
-					var tmBalances []models.LeaveAllocation
-					if err := config.DB.Where("user_id = ?", tm.UserID).Find(&tmBalances).Error; err == nil && len(tmBalances) > 0 {
+					if tmForecasts, err := forecastBalances(tm.UserID, yearEnd); err == nil && len(tmForecasts) > 0 {
 						context += "  Leave Balances: "
-						for i, b := range tmBalances {
-							remaining := b.Total - b.Used
+						for i, f := range tmForecasts {
 							if i > 0 {
 								context += ", "
 							}
-							context += fmt.Sprintf("%s: %d/%d", b.Type, remaining, b.Total)
+							context += fmt.Sprintf("%s: %g remaining now, projected %g by %s", f.Type, f.Remaining, f.Projected, yearEnd.Format("2006-01-02"))
 						}
 						context += "\n"
 					}
@@ -565,59 +569,35 @@ func applyLeave(params map[string]interface{}, userID uint, c *gin.Context) (str
 		reason = r
 	}
 
-	// Parse dates
-	startDate, err := time.Parse("2006-01-02", startDateStr)
-	if err != nil {
-		return "", fmt.Errorf("invalid start_date format: %v", err)
-	}
-
-	endDate, err := time.Parse("2006-01-02", endDateStr)
-	if err != nil {
-		return "", fmt.Errorf("invalid end_date format: %v", err)
-	}
-
-	// Calculate duration
-	duration := int(endDate.Sub(startDate).Hours()/24) + 1
-	if duration <= 0 {
-		return "", fmt.Errorf("end date must be after start date")
-	}
-
-	// Check leave balance
-	var allocation models.LeaveAllocation
-	if err := config.DB.Where("user_id = ? AND type = ?", userID, leaveType).First(&allocation).Error; err != nil {
-		return "", fmt.Errorf("no leave allocation found for type: %s", leaveType)
-	}
-
-	remaining := allocation.Total - allocation.Used
-	if remaining < duration {
-		return "", fmt.Errorf("insufficient leave balance. You have %d days of %s leave remaining, but requested %d days", remaining, leaveType, duration)
-	}
-
-	// Create leave request
-	leave := models.Leave{
-		UserID:    userID,
-		StartDate: startDate,
-		EndDate:   endDate,
+	// Same validation, balance check and ledger debit as POST /api/leaves
+	sub, lerr := submitLeave(userID, LeaveRequest{
+		StartDate: startDateStr,
+		EndDate:   endDateStr,
 		Type:      leaveType,
 		Reason:    reason,
-		Status:    "pending",
+	})
+	if lerr != nil {
+		if lerr.Body["error"] == "insufficient balance" {
+			return "", fmt.Errorf("insufficient leave balance. You have %v days of %s leave remaining, but requested %v days",
+				lerr.Body["remaining"], leaveType, lerr.Body["requested"])
+		}
+		return "", lerr
 	}
 
-	if err := config.DB.Create(&leave).Error; err != nil {
-		return "", fmt.Errorf("failed to create leave request: %v", err)
-	}
+	var allocation models.LeaveAllocation
+	config.DB.Where("user_id = ? AND year = ? AND type = ?", userID, sub.Leave.StartDate.Year(), sub.Leave.Type).First(&allocation)
 
 	// Success message
 	successMsg := fmt.Sprintf("✅ Leave request submitted successfully!\n\n"+
-		"📅 Dates: %s to %s (%d days)\n"+
+		"📅 Dates: %s to %s (%g days)\n"+
 		"📝 Type: %s\n"+
 		"⏳ Status: Pending approval\n"+
-		"💼 Remaining balance: %d/%d days",
-		startDate.Format("Jan 02, 2006"),
-		endDate.Format("Jan 02, 2006"),
-		duration,
-		leaveType,
-		remaining-duration,
+		"💼 Remaining balance: %g/%g days",
+		sub.Leave.StartDate.Format("Jan 02, 2006"),
+		sub.Leave.EndDate.Format("Jan 02, 2006"),
+		sub.Days,
+		sub.Leave.Type,
+		allocation.Total-allocation.Used,
 		allocation.Total)
 
 	if reason != "" {
@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/gin-gonic/gin"
+)
+
+// leave type credited by approved comp-off requests
+const compOffLeaveType = "comp_off"
+
+type CompOffRequestBody struct {
+	WorkedOn string  `json:"worked_on"` // "YYYY-MM-DD", a weekend or holiday
+	Days     float64 `json:"days"`      // 1 (default) or 0.5 for half a day worked
+	Reason   string  `json:"reason"`
+}
+
+// compOffExpiryDays is how long an approved comp-off credit can be used
+// (COMP_OFF_EXPIRY_DAYS, default 90). Claims must also be made within it.
+func compOffExpiryDays() int {
+	if v, err := strconv.Atoi(os.Getenv("COMP_OFF_EXPIRY_DAYS")); err == nil && v > 0 {
+		return v
+	}
+	return 90
+}
+
+// POST /api/leaves/comp-off
+// Body: {"worked_on": "2025-08-16", "days": 1, "reason": "release weekend"}
+func CreateCompOffRequest(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CompOffRequestBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	workedOn, err := time.Parse("2006-01-02", req.WorkedOn)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, expected YYYY-MM-DD"})
+		return
+	}
+	today := time.Now().Truncate(24 * time.Hour)
+	if workedOn.After(today) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "comp-off can only be claimed for a day already worked"})
+		return
+	}
+	if workedOn.Before(today.AddDate(0, 0, -compOffExpiryDays())) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("comp-off must be claimed within %d days", compOffExpiryDays())})
+		return
+	}
+
+	if req.Days == 0 {
+		req.Days = 1
+	}
+	if req.Days != 1 && req.Days != 0.5 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be 1 or 0.5"})
+		return
+	}
+
+	key := workedOn.Format("2006-01-02")
+	weekend := workedOn.Weekday() == time.Saturday || workedOn.Weekday() == time.Sunday
+	if !weekend && !holidaysBetween(employeeLocation(userID), workedOn, workedOn)[key] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "comp-off can only be claimed for a weekend or holiday"})
+		return
+	}
+
+	policy, err := resolveLeavePolicy(loadLeaveProfile(userID), compOffLeaveType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load leave policy"})
+		return
+	}
+	if policy == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "comp-off is not available to you"})
+		return
+	}
+
+	var existing int64
+	config.DB.Model(&models.CompOffRequest{}).
+		Where("user_id = ? AND worked_on = ? AND status IN ?", userID, workedOn, []string{"pending", "approved"}).
+		Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "comp-off already claimed for this date"})
+		return
+	}
+
+	item := models.CompOffRequest{
+		UserID:   userID,
+		WorkedOn: workedOn,
+		Days:     req.Days,
+		Reason:   strings.TrimSpace(req.Reason),
+		Status:   "pending",
+	}
+	if err := config.DB.Create(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create comp-off request"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": item})
+}
+
+// GET /api/leaves/comp-off?status=
+// The caller's own comp-off requests plus the ones they can decide on.
+func ListCompOffRequests(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	q := resolveTeamScope(userID, c.GetString("role")).applyReports(config.DB.Model(&models.CompOffRequest{}), "user_id", userID)
+	if s := c.Query("status"); s != "" {
+		q = q.Where("status = ?", strings.ToLower(s))
+	}
+
+	var items []models.CompOffRequest
+	if err := q.Order("created_at desc").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load comp-off requests"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// PUT /api/leaves/comp-off/:id/approve
+// Body (optional): {"comment": "..."}
+// Credits the days to the employee's comp-off allocation for the current year.
+func ApproveCompOffRequest(c *gin.Context) {
+	decideCompOff(c, true)
+}
+
+// PUT /api/leaves/comp-off/:id/reject
+// Body (optional): {"comment": "..."}
+func RejectCompOffRequest(c *gin.Context) {
+	decideCompOff(c, false)
+}
+
+func decideCompOff(c *gin.Context, approve bool) {
+	userID := c.GetUint("userID")
+	role := c.GetString("role")
+	comment := bindDecisionComment(c)
+
+	var item models.CompOffRequest
+	if err := config.DB.Where("id = ? AND status = ?", c.Param("id"), "pending").First(&item).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "comp-off request not found or not pending"})
+		return
+	}
+	if !canAcknowledgeFor(userID, role, item.UserID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the employee's manager or HR can decide on comp-off"})
+		return
+	}
+
+	now := time.Now()
+	item.DecidedBy, item.DecidedAt, item.Comment = &userID, &now, comment
+	if !approve {
+		item.Status = "rejected"
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := lockPending(tx, &models.CompOffRequest{}, item.ID); err != nil {
+				return err
+			}
+			return tx.Save(&item).Error
+		})
+		if errors.Is(err, errAlreadyDecided) {
+			c.JSON(http.StatusConflict, gin.H{"error": "comp-off request was decided in the meantime"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update comp-off request"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": item})
+		return
+	}
+
+	today := now.Truncate(24 * time.Hour)
+	expiresOn := today.AddDate(0, 0, compOffExpiryDays())
+	item.Status, item.Year, item.ExpiresOn = "approved", today.Year(), &expiresOn
+
+	var alloc *models.LeaveAllocation
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockPending(tx, &models.CompOffRequest{}, item.ID); err != nil {
+			return err
+		}
+		var err error
+		if alloc, err = lockAllocation(tx, item.UserID, item.Year, compOffLeaveType); err != nil {
+			return err
+		}
+		if err := tx.Save(&item).Error; err != nil {
+			return err
+		}
+		return postLeaveTransaction(tx, alloc, models.LeaveTransaction{
+			Kind:      models.LeaveTxnGrant,
+			Days:      item.Days,
+			Reason:    "comp-off for work on " + item.WorkedOn.Format("2006-01-02"),
+			CreatedBy: &userID,
+		})
+	})
+	if errors.Is(err, errAlreadyDecided) {
+		c.JSON(http.StatusConflict, gin.H{"error": "comp-off request was decided in the meantime"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to credit comp-off"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": item, "balance": leaveBalanceFor(*alloc, nil)})
+}
+
+// ExpireCompOffCredits lapses whatever is left of comp-off credits whose
+// expiry date has passed. Comp-off days are taken oldest credit first, so the
+// remaining balance belongs to the newest credits; an expiring credit only
+// loses the part of the balance not claimed by credits that are still live.
+func ExpireCompOffCredits(asOf time.Time) error {
+	today := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+
+	var credits []models.CompOffRequest
+	if err := config.DB.
+		Where("status = ? AND expires_on < ? AND expired_at IS NULL", "approved", today).
+		Order("expires_on asc, id asc").
+		Find(&credits).Error; err != nil {
+		return err
+	}
+
+	for _, credit := range credits {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			// unexpired balance has been carried into the current year at rollover
+			var alloc models.LeaveAllocation
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("user_id = ? AND year = ? AND type = ?", credit.UserID, today.Year(), compOffLeaveType).
+				First(&alloc).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			var newer float64
+			if err := tx.Model(&models.CompOffRequest{}).
+				Select("COALESCE(SUM(days), 0)").
+				Where("user_id = ? AND status = ? AND expired_at IS NULL AND id <> ?", credit.UserID, "approved", credit.ID).
+				Where("expires_on > ? OR (expires_on = ? AND id > ?)", credit.ExpiresOn, credit.ExpiresOn, credit.ID).
+				Scan(&newer).Error; err != nil {
+				return err
+			}
+
+			expired := 0.0
+			if alloc.ID != 0 {
+				expired = roundDays(min(max(alloc.Total-alloc.Used-newer, 0), credit.Days))
+				if expired > 0 {
+					if err := postLeaveTransaction(tx, &alloc, models.LeaveTransaction{
+						Kind:   models.LeaveTxnExpiry,
+						Days:   -expired,
+						Reason: "comp-off for work on " + credit.WorkedOn.Format("2006-01-02") + " expired",
+					}); err != nil {
+						return err
+					}
+				}
+			}
+
+			now := time.Now()
+			return tx.Model(&credit).Updates(map[string]any{
+				"expired_days": expired,
+				"expired_at":   now,
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("comp-off expiry for request %d: %w", credit.ID, err)
+		}
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http"
 	"peoplesoft/config"
+	"peoplesoft/models"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -144,7 +146,7 @@ func GetDashboardStats(c *gin.Context) {
 
 	// Recent activity
 	activity := getRecentActivity(role, userID)
-	upcomingEvents := getUpcomingEvents()
+	upcomingEvents := getUpcomingEvents(userID)
 
 	response := gin.H{
 		"stats":             stats,
@@ -285,17 +287,28 @@ func getRecentActivity(role string, userID uint) []RecentActivity {
 	return activities
 }
 
-func getUpcomingEvents() []UpcomingEvent {
-	return []UpcomingEvent{
-		{
-			Date:  "25 DEC",
-			Title: "Christmas Day",
-			Desc:  "Company Holiday - Office Closed",
-		},
-		{
-			Date:  "01 JAN",
-			Title: "New Year's Day",
-			Desc:  "Company Holiday - Office Closed",
-		},
+// getUpcomingEvents lists the next company holidays observed at the user's office.
+func getUpcomingEvents(userID uint) []UpcomingEvent {
+	today := time.Now().Truncate(24 * time.Hour)
+
+	var holidays []models.Holiday
+	scopeHolidaysToLocation(config.DB.Model(&models.Holiday{}), employeeLocation(userID)).
+		Where("date >= ?", today).
+		Order("date asc").
+		Limit(5).
+		Find(&holidays)
+
+	events := []UpcomingEvent{}
+	for _, h := range holidays {
+		desc := h.Description
+		if desc == "" {
+			desc = "Company Holiday - Office Closed"
+		}
+		events = append(events, UpcomingEvent{
+			Date:  strings.ToUpper(h.Date.Format("02 Jan")),
+			Title: h.Name,
+			Desc:  desc,
+		})
 	}
+	return events
 }
@@ -0,0 +1,352 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DepartmentRequest is the body of department create and update. On update,
+// omitted fields keep their value and a parent_id or head_id of 0 clears it.
+type DepartmentRequest struct {
+	Name       *string `json:"name"`
+	ParentID   *uint   `json:"parent_id"`
+	HeadID     *uint   `json:"head_id"` // employee id
+	CostCenter *string `json:"cost_center"`
+}
+
+// DepartmentRow is a department with its names resolved and people counted.
+type DepartmentRow struct {
+	ID             uint             `json:"id"`
+	Name           string           `json:"name"`
+	ParentID       *uint            `json:"parent_id"`
+	ParentName     *string          `json:"parent_name"`
+	HeadID         *uint            `json:"head_id"`
+	HeadName       *string          `json:"head_name"`
+	CostCenter     string           `json:"cost_center"`
+	Headcount      int              `json:"headcount"`       // employees in the department itself
+	TotalHeadcount int              `json:"total_headcount"` // including every sub-department
+	SubDepartments int              `json:"sub_departments"` // direct and indirect
+	Children       []*DepartmentRow `json:"children,omitempty"`
+}
+
+const departmentsSQL = `
+SELECT d.id, d.name, d.parent_id, p.name AS parent_name, d.head_id, hu.name AS head_name, d.cost_center,
+	(SELECT COUNT(*) FROM employees e WHERE e.department_id = d.id) AS headcount
+FROM departments d
+LEFT JOIN departments p ON p.id = d.parent_id
+LEFT JOIN employees he ON he.id = d.head_id
+LEFT JOIN users hu ON hu.id = he.user_id
+ORDER BY d.name`
+
+// Like the org chart queries, the path guards against a parent cycle.
+const departmentRollupSQL = `
+WITH RECURSIVE tree AS (
+	SELECT d.id AS root_id, d.id, ARRAY[d.id] AS path
+	FROM departments d
+	UNION ALL
+	SELECT t.root_id, c.id, t.path || c.id
+	FROM departments c
+	JOIN tree t ON c.parent_id = t.id
+	WHERE NOT c.id = ANY(t.path)
+)
+SELECT t.root_id AS id, COUNT(e.id) AS total_headcount, COUNT(DISTINCT t.id) - 1 AS sub_departments
+FROM tree t
+LEFT JOIN employees e ON e.department_id = t.id
+GROUP BY t.root_id`
+
+// EnsureDepartmentNameIndex makes department names unique regardless of case,
+// so concurrent creates and renames cannot both pass the name check. Run at
+// startup after AutoMigrate; fails while duplicate names remain.
+func EnsureDepartmentNameIndex() error {
+	return config.DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_departments_lower_name ON departments (LOWER(name))").Error
+}
+
+// loadDepartments returns every department with its roll-up counts, by name.
+func loadDepartments() ([]*DepartmentRow, error) {
+	var rows []*DepartmentRow
+	if err := config.DB.Raw(departmentsSQL).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	var rollups []struct {
+		ID             uint
+		TotalHeadcount int
+		SubDepartments int
+	}
+	if err := config.DB.Raw(departmentRollupSQL).Scan(&rollups).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*DepartmentRow, len(rows))
+	for _, r := range rows {
+		byID[r.ID] = r
+	}
+	for _, r := range rollups {
+		if d, ok := byID[r.ID]; ok {
+			d.TotalHeadcount, d.SubDepartments = r.TotalHeadcount, r.SubDepartments
+		}
+	}
+	return rows, nil
+}
+
+// departmentWouldCycle reports whether making parentID the parent of id would
+// put the department below itself.
+func departmentWouldCycle(id, parentID uint) (bool, error) {
+	var all []models.Department
+	if err := config.DB.Select("id, parent_id").Find(&all).Error; err != nil {
+		return false, err
+	}
+	parents := make(map[uint]*uint, len(all))
+	for _, d := range all {
+		parents[d.ID] = d.ParentID
+	}
+	seen := map[uint]bool{}
+	for at := &parentID; at != nil && !seen[*at]; at = parents[*at] {
+		if *at == id {
+			return true, nil
+		}
+		seen[*at] = true
+	}
+	return false, nil
+}
+
+// applyDepartmentRequest validates req and copies it onto dept.
+func applyDepartmentRequest(dept *models.Department, req DepartmentRequest) (int, string) {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return http.StatusBadRequest, "name is required"
+		}
+		// imports and reports resolve departments by name
+		var clash int64
+		config.DB.Model(&models.Department{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, dept.ID).Count(&clash)
+		if clash > 0 {
+			return http.StatusConflict, "a department with this name already exists"
+		}
+		dept.Name = name
+	}
+	if req.ParentID != nil {
+		if *req.ParentID == 0 {
+			dept.ParentID = nil
+		} else {
+			var parent models.Department
+			if err := config.DB.First(&parent, *req.ParentID).Error; err != nil {
+				return http.StatusBadRequest, "parent department not found"
+			}
+			if dept.ID != 0 {
+				cycle, err := departmentWouldCycle(dept.ID, parent.ID)
+				if err != nil {
+					return http.StatusInternalServerError, "failed to check department hierarchy"
+				}
+				if cycle {
+					return http.StatusBadRequest, "a department cannot sit below itself"
+				}
+			}
+			dept.ParentID = &parent.ID
+		}
+	}
+	if req.HeadID != nil {
+		if *req.HeadID == 0 {
+			dept.HeadID = nil
+		} else {
+			var head models.Employee
+			if err := config.DB.First(&head, *req.HeadID).Error; err != nil {
+				return http.StatusBadRequest, "department head must be an employee"
+			}
+			dept.HeadID = &head.ID
+		}
+	}
+	if req.CostCenter != nil {
+		dept.CostCenter = strings.TrimSpace(*req.CostCenter)
+	}
+	return 0, ""
+}
+
+// GET /api/departments?tree=true
+// Every department with head, parent and headcounts; tree nests sub-departments
+// under their parents.
+func ListDepartments(c *gin.Context) {
+	rows, err := loadDepartments()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load departments"})
+		return
+	}
+	if c.Query("tree") != "true" {
+		c.JSON(http.StatusOK, gin.H{"data": rows})
+		return
+	}
+
+	byID := make(map[uint]*DepartmentRow, len(rows))
+	for _, r := range rows {
+		byID[r.ID] = r
+	}
+	roots := []*DepartmentRow{}
+	for _, r := range rows {
+		if r.ParentID != nil {
+			if parent, ok := byID[*r.ParentID]; ok {
+				parent.Children = append(parent.Children, r)
+				continue
+			}
+		}
+		roots = append(roots, r)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": roots, "count": len(rows)})
+}
+
+// GET /api/departments/:id
+// The department with its direct sub-departments.
+func GetDepartment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid department id"})
+		return
+	}
+	rows, err := loadDepartments()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load departments"})
+		return
+	}
+
+	var dept *DepartmentRow
+	children := []*DepartmentRow{}
+	for _, r := range rows {
+		if r.ID == uint(id) {
+			dept = r
+		} else if r.ParentID != nil && *r.ParentID == uint(id) {
+			children = append(children, r)
+		}
+	}
+	if dept == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+	dept.Children = children
+	c.JSON(http.StatusOK, gin.H{"data": dept})
+}
+
+// POST /api/departments (HR only)
+// Body: {"name": "Engineering", "parent_id": 1, "head_id": 12, "cost_center": "CC-100"}
+func CreateDepartment(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage departments"})
+		return
+	}
+	var req DepartmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	var dept models.Department
+	if status, msg := applyDepartmentRequest(&dept, req); status != 0 {
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+	if err := config.DB.Create(&dept).Error; err != nil {
+		// the unique index on LOWER(name) catches a concurrent create of the same name
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"error": "a department with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create department"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": dept})
+}
+
+// PUT /api/departments/:id (HR only)
+func UpdateDepartment(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage departments"})
+		return
+	}
+	var dept models.Department
+	if err := config.DB.First(&dept, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	var req DepartmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if status, msg := applyDepartmentRequest(&dept, req); status != 0 {
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+	// Save writes the nil pointers too, so cleared parents and heads stick
+	if err := config.DB.Save(&dept).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"error": "a department with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update department"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": dept})
+}
+
+// DELETE /api/departments/:id (HR only)
+// Only empty departments can go: no employees, users, positions,
+// sub-departments or leave policies may still point at it.
+func DeleteDepartment(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage departments"})
+		return
+	}
+	var dept models.Department
+	if err := config.DB.First(&dept, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+
+	errInUse := errors.New("department in use")
+	var usage struct{ Employees, Users, Positions, Children, Policies int64 }
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		counts := []struct {
+			model  any
+			column string
+			dest   *int64
+		}{
+			{&models.Employee{}, "department_id", &usage.Employees},
+			{&models.User{}, "department_id", &usage.Users},
+			{&models.Position{}, "department_id", &usage.Positions},
+			{&models.Department{}, "parent_id", &usage.Children},
+			{&models.LeavePolicy{}, "department_id", &usage.Policies},
+		}
+		for _, n := range counts {
+			if err := tx.Model(n.model).Where(n.column+" = ?", dept.ID).Count(n.dest).Error; err != nil {
+				return err
+			}
+		}
+		if usage.Employees+usage.Users+usage.Positions+usage.Children+usage.Policies > 0 {
+			return errInUse
+		}
+		return tx.Delete(&dept).Error
+	})
+	if errors.Is(err, errInUse) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           "move its employees, users, positions, sub-departments and leave policies elsewhere first",
+			"employees":       usage.Employees,
+			"users":           usage.Users,
+			"positions":       usage.Positions,
+			"sub_departments": usage.Children,
+			"leave_policies":  usage.Policies,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete department"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
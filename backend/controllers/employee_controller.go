@@ -1,28 +1,44 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"peoplesoft/config"
 	"peoplesoft/models"
 	"strconv"
 	"strings"
+	"time"
+
+	"gorm.io/gorm"
 
 	"github.com/gin-gonic/gin"
 )
 
 // DTO to include user fields in the directory row
 type EmployeeRow struct {
-	ID           uint    `json:"id"`
-	UserID       uint    `json:"user_id"`
-	Name         string  `json:"name"`
-	Email        string  `json:"email"`
-	Designation  string  `json:"designation"`
-	DepartmentID uint    `json:"department_id"`
-	ManagerID    *uint   `json:"manager_id"`
-	ManagerName  *string `json:"manager_name"`
-	Phone        string  `json:"phone"`
-	Location     string  `json:"location"`
+	ID             uint    `json:"id"`
+	UserID         uint    `json:"user_id"`
+	Name           string  `json:"name"`
+	Email          string  `json:"email"`
+	Designation    string  `json:"designation"`
+	DepartmentID   uint    `json:"department_id"`
+	DepartmentName *string `json:"department_name"`
+	ManagerID      *uint   `json:"manager_id"`
+	ManagerName    *string `json:"manager_name"`
+	Phone          string  `json:"phone"`
+	Location       string  `json:"location"`
+}
+
+// employeeDirectory selects EmployeeRow columns from employees e.
+func employeeDirectory() *gorm.DB {
+	return config.DB.Table("employees e").
+		Select(`e.id, e.user_id, u.name, u.email, e.designation, e.department_id, d.name as department_name,
+			e.manager_id, mu.name as manager_name, e.phone, e.location`).
+		Joins("JOIN users u ON u.id = e.user_id").
+		Joins("LEFT JOIN departments d ON d.id = e.department_id").
+		Joins("LEFT JOIN employees me ON me.id = e.manager_id").
+		Joins("LEFT JOIN users mu ON mu.id = me.user_id")
 }
 
 // GET /api/employees?q=&department_id=&designation=&page=&page_size=
@@ -39,12 +55,7 @@ func ListEmployees(c *gin.Context) {
 		size = 10
 	}
 
-	db := config.DB.Table("employees e").
-		Select(`e.id, e.user_id, u.name, u.email, e.designation, e.department_id, e.manager_id,
-			mu.name as manager_name, e.phone, e.location`).
-		Joins("JOIN users u ON u.id = e.user_id").
-		Joins("LEFT JOIN employees me ON me.id = e.manager_id").
-		Joins("LEFT JOIN users mu ON mu.id = me.user_id")
+	db := employeeDirectory()
 
 	if q != "" {
 		like := "%" + q + "%"
@@ -84,10 +95,19 @@ func CreateEmployee(c *gin.Context) {
 		return
 	}
 
+	// positions are filled through PUT /api/positions/:id/assign
+	emp.PositionID = nil
+
 	// Add logging
 	fmt.Printf("Creating employee: %+v\n", emp)
 
-	if err := config.DB.Create(&emp).Error; err != nil {
+	// the hire row starts the employee's job history
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&emp).Error; err != nil {
+			return err
+		}
+		return ensureJobHistory(tx, emp)
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create employee: " + err.Error()})
 		return
 	}
@@ -100,12 +120,7 @@ func CreateEmployee(c *gin.Context) {
 func GetEmployee(c *gin.Context) {
 	id := c.Param("id")
 	var row EmployeeRow
-	err := config.DB.Table("employees e").
-		Select(`e.id, e.user_id, u.name, u.email, e.designation, e.department_id, e.manager_id,
-			mu.name as manager_name, e.phone, e.location`).
-		Joins("JOIN users u ON u.id = e.user_id").
-		Joins("LEFT JOIN employees me ON me.id = e.manager_id").
-		Joins("LEFT JOIN users mu ON mu.id = me.user_id").
+	err := employeeDirectory().
 		Where("e.id = ?", id).
 		Scan(&row).Error
 	if err != nil {
@@ -120,58 +135,130 @@ func GetEmployee(c *gin.Context) {
 }
 
 // PUT /api/employees/:id
+// Designation, department, manager and location changes are recorded as a job
+// row effective on effective_date (default today) with an action (default
+// data_change) and reason; a future-dated change applies on its date. Phone
+// is updated directly. A position holder's designation and department come
+// from the position, so they change through PUT /api/positions/:id instead.
 func UpdateEmployee(c *gin.Context) {
-	id := c.Param("id")
 	var in struct {
-		Designation  *string `json:"designation"`
-		DepartmentID *uint   `json:"department_id"`
-		ManagerID    *uint   `json:"manager_id"`
-		Phone        *string `json:"phone"`
-		Location     *string `json:"location"`
+		Designation   *string `json:"designation"`
+		DepartmentID  *uint   `json:"department_id"`
+		ManagerID     *uint   `json:"manager_id"`
+		Phone         *string `json:"phone"`
+		Location      *string `json:"location"`
+		EffectiveDate *string `json:"effective_date"` // YYYY-MM-DD
+		Action        string  `json:"action"`
+		Reason        string  `json:"reason"`
 	}
 	if err := c.ShouldBindJSON(&in); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
 		return
 	}
-	updates := map[string]any{}
-	if in.Designation != nil {
-		updates["designation"] = *in.Designation
+
+	var emp models.Employee
+	if err := config.DB.First(&emp, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
 	}
-	if in.DepartmentID != nil {
-		updates["department_id"] = *in.DepartmentID
+
+	change := jobChange{Designation: in.Designation, DepartmentID: in.DepartmentID, ManagerID: in.ManagerID, Location: in.Location}
+	effective := time.Now().Truncate(24 * time.Hour)
+	if in.EffectiveDate != nil {
+		d, err := time.Parse("2006-01-02", *in.EffectiveDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid effective_date, expected YYYY-MM-DD"})
+			return
+		}
+		effective = d
 	}
-	if in.ManagerID != nil {
-		updates["manager_id"] = in.ManagerID
+	action := strings.ToLower(strings.TrimSpace(in.Action))
+	if action == "" {
+		action = models.JobActionDataChange
 	}
-	if in.Phone != nil {
-		updates["phone"] = *in.Phone
+	if !jobActions[action] || action == models.JobActionHire {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be one of promotion, transfer, reorg, data_change"})
+		return
 	}
-	if in.Location != nil {
-		updates["location"] = *in.Location
+
+	if emp.PositionID != nil && (in.Designation != nil || in.DepartmentID != nil) {
+		var pos models.Position
+		if err := config.DB.First(&pos, *emp.PositionID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load the employee's position"})
+			return
+		}
+		if (in.Designation != nil && *in.Designation != pos.Title) || (in.DepartmentID != nil && *in.DepartmentID != pos.DepartmentID) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":       "the employee holds a position; update the position or assign them to another one",
+				"position_id": pos.ID,
+			})
+			return
+		}
 	}
 
-	tx := config.DB.Model(&models.Employee{}).Where("id = ?", id).Updates(updates)
-	if tx.Error != nil {
+	if in.ManagerID != nil {
+		cycle, err := wouldCreateCycle(config.DB, emp.ID, *in.ManagerID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check reporting line"})
+			return
+		}
+		if cycle {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "an employee cannot report to themselves, directly or indirectly"})
+			return
+		}
+	}
+
+	var job *models.EmployeeJob
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if in.Phone != nil {
+			if err := tx.Model(&emp).Update("phone", *in.Phone).Error; err != nil {
+				return err
+			}
+		}
+		if change.empty() {
+			return nil
+		}
+		var err error
+		job, err = recordJobChange(tx, emp, change, effective, action, strings.TrimSpace(in.Reason), c.GetUint("userID"))
+		return err
+	})
+	if errors.Is(err, errBeforeFirstJob) || errors.Is(err, errManagerCycle) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
 		return
 	}
-	if tx.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	if job == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "updated"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "updated"})
+	c.JSON(http.StatusOK, gin.H{"message": "updated", "job": job, "scheduled": job.EffectiveDate.After(time.Now())})
 }
 
 // DELETE /api/employees/:id
 func DeleteEmployee(c *gin.Context) {
-	id := c.Param("id")
-	tx := config.DB.Delete(&models.Employee{}, id)
-	if tx.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+	var emp models.Employee
+	if err := config.DB.First(&emp, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 		return
 	}
-	if tx.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&emp).Error; err != nil {
+			return err
+		}
+		// departments they headed have no head until one is named
+		if err := tx.Model(&models.Department{}).Where("head_id = ?", emp.ID).Update("head_id", nil).Error; err != nil {
+			return err
+		}
+		// their position opens again
+		if emp.PositionID != nil {
+			return refreshPositionStatus(tx, *emp.PositionID)
+		}
+		return nil
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
@@ -181,12 +268,7 @@ func DeleteEmployee(c *gin.Context) {
 func ListTeam(c *gin.Context) {
 	managerID := c.Param("managerId")
 	var rows []EmployeeRow
-	err := config.DB.Table("employees e").
-		Select(`e.id, e.user_id, u.name, u.email, e.designation, e.department_id, e.manager_id,
-			mu.name as manager_name, e.phone, e.location`).
-		Joins("JOIN users u ON u.id = e.user_id").
-		Joins("LEFT JOIN employees me ON me.id = e.manager_id").
-		Joins("LEFT JOIN users mu ON mu.id = me.user_id").
+	err := employeeDirectory().
 		Where("e.manager_id = ?", managerID).
 		Order("u.name asc").
 		Scan(&rows).Error
@@ -220,12 +302,7 @@ func ListMyTeam(c *gin.Context) {
 
 	// fetch team (direct reports)
 	var rows []EmployeeRow
-	if err := config.DB.Table("employees e").
-		Select(`e.id, e.user_id, u.name, u.email, e.designation, e.department_id, e.manager_id,
-			mu.name as manager_name, e.phone, e.location`).
-		Joins("JOIN users u ON u.id = e.user_id").
-		Joins("LEFT JOIN employees me ON me.id = e.manager_id").
-		Joins("LEFT JOIN users mu ON mu.id = me.user_id").
+	if err := employeeDirectory().
 		Where("e.manager_id = ?", managerEmp.ID).
 		Order("u.name asc").
 		Scan(&rows).Error; err != nil {
@@ -0,0 +1,396 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"path/filepath"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxImportSize = 5 << 20
+	maxImportRows = 1000
+)
+
+// importColumns are the columns an import file may have, in any order.
+// name and email are required.
+var importColumns = map[string]bool{
+	"name": true, "email": true, "role": true, "designation": true, "department": true,
+	"manager_email": true, "phone": true, "location": true, "hire_date": true,
+}
+
+var importRoles = map[string]bool{"employee": true, "manager": true, "hr": true}
+
+// ImportRowResult is the validation outcome of one line of an import file.
+type ImportRowResult struct {
+	Row        int      `json:"row"` // line in the file; the header is line 1
+	Email      string   `json:"email"`
+	Status     string   `json:"status"` // valid, invalid or created
+	Errors     []string `json:"errors"`
+	EmployeeID uint     `json:"employee_id,omitempty"`
+	// set once the user is created; shown only in the import response
+	TemporaryPassword string `json:"temporary_password,omitempty"`
+}
+
+// importRow is a parsed line of an import file.
+type importRow struct {
+	Name, Email, Role, Designation string
+	Department, ManagerEmail       string
+	Phone, Location                string
+	DepartmentID                   uint
+	ManagerID                      *uint // an existing employee; managers in the file are linked on commit
+	HireDate                       time.Time
+	result                         *ImportRowResult
+}
+
+func (r *importRow) fail(format string, args ...any) {
+	r.result.Errors = append(r.result.Errors, fmt.Sprintf(format, args...))
+}
+
+// readImportFile reads the "file" part of the request as CSV or XLSX records.
+func readImportFile(c *gin.Context) ([][]string, error) {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return nil, fmt.Errorf("expected a multipart form with a CSV or XLSX file field")
+	}
+	if fh.Size > maxImportSize {
+		return nil, fmt.Errorf("%s is larger than %d MB", fh.Filename, maxImportSize>>20)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxImportSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(fh.Filename), ".xlsx") || bytes.HasPrefix(data, []byte("PK")) {
+		return utils.ReadXLSX(bytes.NewReader(data), int64(len(data)))
+	}
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	return r.ReadAll()
+}
+
+// parseImportRows maps the records onto the header columns. Blank lines are
+// skipped; an unknown or missing required column fails the whole file.
+func parseImportRows(records [][]string) ([]*importRow, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("the file is empty")
+	}
+	cols := map[string]int{}
+	for i, h := range records[0] {
+		name := strings.ToLower(strings.TrimSpace(h))
+		if name == "" {
+			continue
+		}
+		if !importColumns[name] {
+			return nil, fmt.Errorf("unknown column %q", h)
+		}
+		cols[name] = i
+	}
+	for _, required := range []string{"name", "email"} {
+		if _, ok := cols[required]; !ok {
+			return nil, fmt.Errorf("missing required column %q", required)
+		}
+	}
+
+	var rows []*importRow
+	for n, rec := range records[1:] {
+		get := func(col string) string {
+			i, ok := cols[col]
+			if !ok || i >= len(rec) {
+				return ""
+			}
+			return strings.TrimSpace(rec[i])
+		}
+		if strings.TrimSpace(strings.Join(rec, "")) == "" {
+			continue
+		}
+		rows = append(rows, &importRow{
+			Name:         get("name"),
+			Email:        strings.ToLower(get("email")),
+			Role:         strings.ToLower(get("role")),
+			Designation:  get("designation"),
+			Department:   get("department"),
+			ManagerEmail: strings.ToLower(get("manager_email")),
+			Phone:        get("phone"),
+			Location:     get("location"),
+			result:       &ImportRowResult{Row: n + 2, Email: strings.ToLower(get("email")), Errors: []string{}},
+		})
+		if v := get("hire_date"); v != "" {
+			row := rows[len(rows)-1]
+			if d, err := time.Parse("2006-01-02", v); err == nil {
+				row.HireDate = d
+			} else if serial, err := strconv.ParseFloat(v, 64); err == nil {
+				row.HireDate = utils.XLSXDate(serial)
+			} else {
+				row.fail("hire_date %q is not a YYYY-MM-DD date", v)
+			}
+		}
+	}
+	if len(rows) > maxImportRows {
+		return nil, fmt.Errorf("at most %d employees per import", maxImportRows)
+	}
+	return rows, nil
+}
+
+// validateImportRows checks every row against the database and the rest of
+// the file, resolving departments by name and managers by email. A manager
+// may be an existing employee or another row of the same file.
+func validateImportRows(rows []*importRow) error {
+	var departments []models.Department
+	if err := config.DB.Find(&departments).Error; err != nil {
+		return err
+	}
+	deptByName := map[string]uint{}
+	for _, d := range departments {
+		deptByName[strings.ToLower(d.Name)] = d.ID
+	}
+
+	var emails, managerEmails []string
+	for _, r := range rows {
+		emails = append(emails, r.Email)
+		if r.ManagerEmail != "" {
+			managerEmails = append(managerEmails, r.ManagerEmail)
+		}
+	}
+	var taken []string
+	if err := config.DB.Model(&models.User{}).Where("LOWER(email) IN ?", emails).Pluck("LOWER(email)", &taken).Error; err != nil {
+		return err
+	}
+	existingUser := map[string]bool{}
+	for _, e := range taken {
+		existingUser[e] = true
+	}
+	var managers []struct {
+		ID    uint
+		Email string
+	}
+	if len(managerEmails) > 0 {
+		if err := config.DB.Table("employees e").
+			Select("e.id, LOWER(u.email) AS email").
+			Joins("JOIN users u ON u.id = e.user_id").
+			Where("LOWER(u.email) IN ?", managerEmails).
+			Scan(&managers).Error; err != nil {
+			return err
+		}
+	}
+	existingManager := map[string]uint{}
+	for _, m := range managers {
+		existingManager[m.Email] = m.ID
+	}
+
+	inFile := map[string]*importRow{}
+	today := time.Now().Truncate(24 * time.Hour)
+	for _, r := range rows {
+		if r.Name == "" {
+			r.fail("name is required")
+		}
+		if r.Email == "" {
+			r.fail("email is required")
+		} else if a, err := mail.ParseAddress(r.Email); err != nil || a.Address != r.Email {
+			r.fail("email %q is not a valid address", r.Email)
+		} else if existingUser[r.Email] {
+			r.fail("a user with email %s already exists", r.Email)
+		} else if first, dup := inFile[r.Email]; dup {
+			r.fail("email %s is repeated from row %d", r.Email, first.result.Row)
+		} else {
+			inFile[r.Email] = r
+		}
+
+		if r.Role == "" {
+			r.Role = "employee"
+		} else if !importRoles[r.Role] {
+			r.fail("role must be employee, manager or hr")
+		}
+		if r.Department != "" {
+			if id, ok := deptByName[strings.ToLower(r.Department)]; ok {
+				r.DepartmentID = id
+			} else {
+				r.fail("department %q does not exist", r.Department)
+			}
+		}
+		if r.HireDate.IsZero() {
+			r.HireDate = today
+		} else if r.HireDate.After(today) {
+			r.fail("hire_date cannot be in the future")
+		}
+	}
+
+	for _, r := range rows {
+		switch {
+		case r.ManagerEmail == "":
+		case r.ManagerEmail == r.Email:
+			r.fail("an employee cannot be their own manager")
+		case inFile[r.ManagerEmail] != nil:
+			// walk up the file's reporting lines; existing employees can't
+			// report to new ones, so only rows of the file can loop
+			seen := map[string]bool{r.Email: true}
+			for m := inFile[r.ManagerEmail]; m != nil; m = inFile[m.ManagerEmail] {
+				if seen[m.Email] {
+					r.fail("reporting line through %s loops back on itself", r.ManagerEmail)
+					break
+				}
+				seen[m.Email] = true
+			}
+		case existingManager[r.ManagerEmail] != 0:
+			id := existingManager[r.ManagerEmail]
+			r.ManagerID = &id
+		default:
+			r.fail("manager %s is not an employee and is not in the file", r.ManagerEmail)
+		}
+	}
+
+	for _, r := range rows {
+		r.result.Status = "valid"
+		if len(r.result.Errors) > 0 {
+			r.result.Status = "invalid"
+		}
+	}
+	return nil
+}
+
+// createImportedEmployees creates the user, employee and hire job row of
+// every row in one transaction, then links managers that were in the file.
+func createImportedEmployees(rows []*importRow, hrID uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		byEmail := map[string]*models.Employee{}
+		for _, r := range rows {
+			password, hash, err := temporaryPassword()
+			if err != nil {
+				return err
+			}
+			r.result.TemporaryPassword = password
+			user := models.User{Name: r.Name, Email: r.Email, PasswordHash: string(hash), Role: r.Role, DepartmentID: r.DepartmentID}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			emp := models.Employee{
+				UserID:       user.ID,
+				Designation:  r.Designation,
+				DepartmentID: r.DepartmentID,
+				ManagerID:    r.ManagerID,
+				Phone:        r.Phone,
+				Location:     r.Location,
+			}
+			if err := tx.Create(&emp).Error; err != nil {
+				return err
+			}
+			byEmail[r.Email] = &emp
+		}
+
+		now := time.Now()
+		for _, r := range rows {
+			emp := byEmail[r.Email]
+			if m, ok := byEmail[r.ManagerEmail]; ok {
+				emp.ManagerID = &m.ID
+				if err := tx.Model(emp).Update("manager_id", m.ID).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Create(&models.EmployeeJob{
+				EmployeeID:    emp.ID,
+				EffectiveDate: r.HireDate,
+				Action:        models.JobActionHire,
+				Reason:        "bulk import",
+				Designation:   emp.Designation,
+				DepartmentID:  emp.DepartmentID,
+				ManagerID:     emp.ManagerID,
+				Location:      emp.Location,
+				CreatedBy:     &hrID,
+				AppliedAt:     &now,
+			}).Error; err != nil {
+				return err
+			}
+			r.result.Status, r.result.EmployeeID = "created", emp.ID
+		}
+		return nil
+	})
+}
+
+func importReport(rows []*importRow) []ImportRowResult {
+	results := make([]ImportRowResult, len(rows))
+	for i, r := range rows {
+		results[i] = *r.result
+	}
+	return results
+}
+
+// POST /api/employees/import?dry_run=true (HR only)
+// Multipart form with a CSV or XLSX "file" whose header row names the
+// columns: name, email (required), role, designation, department (by name),
+// manager_email, phone, location, hire_date (YYYY-MM-DD, default today).
+// Every row is validated first and the report returned row by row; with
+// dry_run nothing is written, otherwise the employees are created only if
+// every row is valid. Each created user gets a temporary password, returned
+// in their row of this response only, to log in with and change through
+// PUT /api/me/password.
+func ImportEmployees(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can import employees"})
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	records, err := readImportFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rows, err := parseImportRows(records)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the file has no employee rows"})
+		return
+	}
+	if err := validateImportRows(rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to validate import"})
+		return
+	}
+
+	invalid := 0
+	for _, r := range rows {
+		if r.result.Status == "invalid" {
+			invalid++
+		}
+	}
+
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "valid": len(rows) - invalid, "invalid": invalid, "data": importReport(rows)})
+		return
+	}
+	if invalid > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "the file has invalid rows; nothing was imported",
+			"valid":   len(rows) - invalid,
+			"invalid": invalid,
+			"data":    importReport(rows),
+		})
+		return
+	}
+	if err := createImportedEmployees(rows, c.GetUint("userID")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "import failed; nothing was imported"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"created": len(rows), "data": importReport(rows)})
+}
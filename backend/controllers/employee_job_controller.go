@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+var jobActions = map[string]bool{
+	models.JobActionHire:       true,
+	models.JobActionPromotion:  true,
+	models.JobActionTransfer:   true,
+	models.JobActionReorg:      true,
+	models.JobActionDataChange: true,
+}
+
+// errBeforeFirstJob means a change was dated before the employee's first job row.
+var errBeforeFirstJob = errors.New("effective date is before the employee's first job record")
+
+// errManagerCycle means the manager in a job row now reports to the employee.
+var errManagerCycle = errors.New("the new manager reports to this employee, directly or indirectly")
+
+// jobChange holds new values for an employee's job fields; nil keeps the value.
+type jobChange struct {
+	Designation  *string
+	DepartmentID *uint
+	ManagerID    *uint
+	Location     *string
+}
+
+func (ch jobChange) empty() bool {
+	return ch.Designation == nil && ch.DepartmentID == nil && ch.ManagerID == nil && ch.Location == nil
+}
+
+// apply sets the changed fields on row. When prev is given, a field is only
+// set if row still holds prev's value, which carries a change forward into
+// later rows without undoing the changes those rows made themselves.
+func (ch jobChange) apply(row, prev *models.EmployeeJob) bool {
+	changed := false
+	if ch.Designation != nil && (prev == nil || row.Designation == prev.Designation) && row.Designation != *ch.Designation {
+		row.Designation, changed = *ch.Designation, true
+	}
+	if ch.DepartmentID != nil && (prev == nil || row.DepartmentID == prev.DepartmentID) && row.DepartmentID != *ch.DepartmentID {
+		row.DepartmentID, changed = *ch.DepartmentID, true
+	}
+	if ch.ManagerID != nil && (prev == nil || sameManager(row.ManagerID, prev.ManagerID)) && !sameManager(row.ManagerID, ch.ManagerID) {
+		id := *ch.ManagerID
+		row.ManagerID, changed = &id, true
+	}
+	if ch.Location != nil && (prev == nil || row.Location == prev.Location) && row.Location != *ch.Location {
+		row.Location, changed = *ch.Location, true
+	}
+	return changed
+}
+
+func sameManager(a, b *uint) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// ensureJobHistory gives an employee without job rows a hire row holding their
+// current job, effective from when the employee record was created.
+func ensureJobHistory(db *gorm.DB, emp models.Employee) error {
+	var count int64
+	if err := db.Model(&models.EmployeeJob{}).Where("employee_id = ?", emp.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	effective := emp.CreatedAt.Truncate(24 * time.Hour)
+	if emp.CreatedAt.IsZero() {
+		effective = time.Now().Truncate(24 * time.Hour)
+	}
+	now := time.Now()
+	return db.Create(&models.EmployeeJob{
+		EmployeeID:    emp.ID,
+		EffectiveDate: effective,
+		Action:        models.JobActionHire,
+		Designation:   emp.Designation,
+		DepartmentID:  emp.DepartmentID,
+		ManagerID:     emp.ManagerID,
+		Location:      emp.Location,
+		AppliedAt:     &now,
+	}).Error
+}
+
+// jobAsOf returns the job row in force on date, or nil before the first one.
+func jobAsOf(db *gorm.DB, employeeID uint, date time.Time) (*models.EmployeeJob, error) {
+	var row models.EmployeeJob
+	err := db.Where("employee_id = ? AND effective_date <= ?", employeeID, date).
+		Order("effective_date desc, sequence desc").
+		First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// recordJobChange inserts a job row effective on the given date, carries the
+// change into rows dated after it, and updates the employee if the change is
+// already in force.
+func recordJobChange(tx *gorm.DB, emp models.Employee, change jobChange, effective time.Time, action, reason string, actorID uint) (*models.EmployeeJob, error) {
+	if err := ensureJobHistory(tx, emp); err != nil {
+		return nil, err
+	}
+	base, err := jobAsOf(tx, emp.ID, effective)
+	if err != nil {
+		return nil, err
+	}
+	if base == nil {
+		return nil, errBeforeFirstJob
+	}
+
+	var seq struct{ Next int }
+	if err := tx.Model(&models.EmployeeJob{}).
+		Select("COALESCE(MAX(sequence) + 1, 0) AS next").
+		Where("employee_id = ? AND effective_date = ?", emp.ID, effective).
+		Scan(&seq).Error; err != nil {
+		return nil, err
+	}
+
+	row := *base
+	row.ID, row.CreatedAt, row.AppliedAt = 0, time.Time{}, nil
+	row.EffectiveDate, row.Sequence = effective, seq.Next
+	row.Action, row.Reason, row.CreatedBy = action, reason, &actorID
+	change.apply(&row, nil)
+	if err := tx.Create(&row).Error; err != nil {
+		return nil, err
+	}
+
+	var later []models.EmployeeJob
+	if err := tx.Where("employee_id = ? AND effective_date > ?", emp.ID, effective).
+		Order("effective_date asc, sequence asc").
+		Find(&later).Error; err != nil {
+		return nil, err
+	}
+	for i := range later {
+		if change.apply(&later[i], base) {
+			if err := tx.Save(&later[i]).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := syncEmployeeFromJobs(tx, emp.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// syncEmployeeFromJobs copies the job row in force on asOf onto the employee
+// and marks the rows reached so far as applied.
+func syncEmployeeFromJobs(db *gorm.DB, employeeID uint, asOf time.Time) error {
+	today := asOf.Truncate(24 * time.Hour)
+	current, err := jobAsOf(db, employeeID, today)
+	if err != nil || current == nil {
+		return err
+	}
+	// the hierarchy may have moved since a dated change was recorded
+	var emp models.Employee
+	if err := db.Select("id, manager_id").First(&emp, employeeID).Error; err != nil {
+		return err
+	}
+	if current.ManagerID != nil && !sameManager(emp.ManagerID, current.ManagerID) {
+		cycle, err := wouldCreateCycle(db, employeeID, *current.ManagerID)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return errManagerCycle
+		}
+	}
+	if err := db.Model(&models.Employee{}).Where("id = ?", employeeID).Updates(map[string]interface{}{
+		"designation":   current.Designation,
+		"department_id": current.DepartmentID,
+		"manager_id":    current.ManagerID,
+		"location":      current.Location,
+	}).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.EmployeeJob{}).
+		Where("employee_id = ? AND effective_date <= ? AND applied_at IS NULL", employeeID, today).
+		Update("applied_at", time.Now()).Error
+}
+
+// ApplyEffectiveJobChanges brings employees up to date with job rows whose
+// effective date has been reached. Called by the scheduler. An employee whose
+// new manager would close a reporting loop is left as is and logged until HR
+// corrects the job rows.
+func ApplyEffectiveJobChanges(asOf time.Time) error {
+	var ids []uint
+	if err := config.DB.Model(&models.EmployeeJob{}).
+		Distinct("employee_id").
+		Where("applied_at IS NULL AND effective_date <= ?", asOf.Truncate(24*time.Hour)).
+		Pluck("employee_id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			return syncEmployeeFromJobs(tx, id, asOf)
+		})
+		if errors.Is(err, errManagerCycle) {
+			log.Printf("job changes for employee %d not applied: %v", id, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillJobHistory gives every employee created before job history existed
+// their hire row, so reading the history never has to write. Run at startup
+// after AutoMigrate.
+func BackfillJobHistory() error {
+	var emps []models.Employee
+	if err := config.DB.
+		Where("NOT EXISTS (SELECT 1 FROM employee_jobs j WHERE j.employee_id = employees.id)").
+		Find(&emps).Error; err != nil {
+		return err
+	}
+	if len(emps) == 0 {
+		return nil
+	}
+	log.Printf("backfilling job history for %d employees", len(emps))
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, emp := range emps {
+			if err := ensureJobHistory(tx, emp); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// JobHistoryRow is a job row with the names behind its ids.
+type JobHistoryRow struct {
+	models.EmployeeJob
+	DepartmentName *string `json:"department_name"`
+	ManagerName    *string `json:"manager_name"`
+	CreatedByName  *string `json:"created_by_name"`
+	Current        bool    `json:"current"` // the row in force today
+	Future         bool    `json:"future"`  // not yet effective
+}
+
+// GET /api/employees/:id/history
+// The employee's job rows in effective-date order.
+func GetEmployeeHistory(c *gin.Context) {
+	var emp models.Employee
+	if err := config.DB.First(&emp, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	rows := []JobHistoryRow{}
+	if err := config.DB.Table("employee_jobs j").
+		Select("j.*, d.name AS department_name, mu.name AS manager_name, cu.name AS created_by_name").
+		Joins("LEFT JOIN departments d ON d.id = j.department_id").
+		Joins("LEFT JOIN employees me ON me.id = j.manager_id").
+		Joins("LEFT JOIN users mu ON mu.id = me.user_id").
+		Joins("LEFT JOIN users cu ON cu.id = j.created_by").
+		Where("j.employee_id = ?", emp.ID).
+		Order("j.effective_date asc, j.sequence asc").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load job history"})
+		return
+	}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	current := -1
+	for i := range rows {
+		if rows[i].EffectiveDate.After(today) {
+			rows[i].Future = true
+		} else {
+			current = i
+		}
+	}
+	if current >= 0 {
+		rows[current].Current = true
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
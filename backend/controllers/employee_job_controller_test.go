@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"peoplesoft/models"
+	"testing"
+)
+
+func TestJobChangeApply(t *testing.T) {
+	str := func(v string) *string { return &v }
+	id := func(v uint) *uint { return &v }
+	job := func(designation string, department uint, manager *uint, location string) *models.EmployeeJob {
+		return &models.EmployeeJob{Designation: designation, DepartmentID: department, ManagerID: manager, Location: location}
+	}
+
+	tests := []struct {
+		name        string
+		change      jobChange
+		row         *models.EmployeeJob
+		prev        *models.EmployeeJob
+		want        *models.EmployeeJob
+		wantChanged bool
+	}{
+		{
+			name: "empty change",
+			row:  job("Engineer", 1, id(10), "Pune"),
+			want: job("Engineer", 1, id(10), "Pune"),
+		},
+		{
+			name:        "sets every field without prev",
+			change:      jobChange{Designation: str("Lead"), DepartmentID: id(2), ManagerID: id(11), Location: str("Delhi")},
+			row:         job("Engineer", 1, id(10), "Pune"),
+			want:        job("Lead", 2, id(11), "Delhi"),
+			wantChanged: true,
+		},
+		{
+			name:   "same values are not a change",
+			change: jobChange{Designation: str("Engineer"), DepartmentID: id(1), ManagerID: id(10), Location: str("Pune")},
+			row:    job("Engineer", 1, id(10), "Pune"),
+			want:   job("Engineer", 1, id(10), "Pune"),
+		},
+		{
+			name:        "manager set where there was none",
+			change:      jobChange{ManagerID: id(10)},
+			row:         job("Engineer", 1, nil, "Pune"),
+			want:        job("Engineer", 1, id(10), "Pune"),
+			wantChanged: true,
+		},
+		{
+			name:        "carried into a later row that kept the old values",
+			change:      jobChange{Designation: str("Lead"), ManagerID: id(11)},
+			row:         job("Engineer", 1, id(10), "Pune"),
+			prev:        job("Engineer", 1, id(10), "Pune"),
+			want:        job("Lead", 1, id(11), "Pune"),
+			wantChanged: true,
+		},
+		{
+			name:   "later row that overrides the same field keeps its value",
+			change: jobChange{Designation: str("Lead"), Location: str("Delhi")},
+			row:    job("Manager", 1, id(10), "Mumbai"),
+			prev:   job("Engineer", 1, id(10), "Pune"),
+			want:   job("Manager", 1, id(10), "Mumbai"),
+		},
+		{
+			name:        "later row overrides one field and keeps another",
+			change:      jobChange{Designation: str("Lead"), DepartmentID: id(2)},
+			row:         job("Manager", 1, id(10), "Pune"),
+			prev:        job("Engineer", 1, id(10), "Pune"),
+			want:        job("Manager", 2, id(10), "Pune"),
+			wantChanged: true,
+		},
+		{
+			name:   "later row with its own manager keeps it",
+			change: jobChange{ManagerID: id(11)},
+			row:    job("Engineer", 1, id(12), "Pune"),
+			prev:   job("Engineer", 1, id(10), "Pune"),
+			want:   job("Engineer", 1, id(12), "Pune"),
+		},
+		{
+			name:        "later row without a manager, like prev, gets the new one",
+			change:      jobChange{ManagerID: id(11)},
+			row:         job("Engineer", 1, nil, "Pune"),
+			prev:        job("Engineer", 1, nil, "Pune"),
+			want:        job("Engineer", 1, id(11), "Pune"),
+			wantChanged: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.change.apply(tt.row, tt.prev); got != tt.wantChanged {
+				t.Errorf("apply() = %v, want %v", got, tt.wantChanged)
+			}
+			if tt.row.Designation != tt.want.Designation || tt.row.DepartmentID != tt.want.DepartmentID ||
+				!sameManager(tt.row.ManagerID, tt.want.ManagerID) || tt.row.Location != tt.want.Location {
+				t.Errorf("row = %s/%d/%v/%s, want %s/%d/%v/%s",
+					tt.row.Designation, tt.row.DepartmentID, managerOf(tt.row), tt.row.Location,
+					tt.want.Designation, tt.want.DepartmentID, managerOf(tt.want), tt.want.Location)
+			}
+		})
+	}
+}
+
+func managerOf(row *models.EmployeeJob) any {
+	if row.ManagerID == nil {
+		return nil
+	}
+	return *row.ManagerID
+}
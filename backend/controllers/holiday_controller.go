@@ -0,0 +1,395 @@
+package controllers
+
+import (
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HolidayRequest struct {
+	Name        string `json:"name"`
+	Date        string `json:"date"` // "YYYY-MM-DD"
+	Location    string `json:"location"`
+	Description string `json:"description"`
+}
+
+// GET /api/holidays?year=&location=
+// Without a location filter, non-HR users get the calendar for their own office.
+func ListHolidays(c *gin.Context) {
+	year, err := strconv.Atoi(c.DefaultQuery("year", strconv.Itoa(time.Now().Year())))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+		return
+	}
+
+	q := config.DB.Model(&models.Holiday{}).
+		Where("date >= ? AND date < ?",
+			time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	location, hasLocation := c.GetQuery("location")
+	if !hasLocation && c.GetString("role") != "hr" {
+		location, hasLocation = employeeLocation(c.GetUint("userID")), true
+	}
+	if hasLocation {
+		q = scopeHolidaysToLocation(q, location)
+	}
+
+	var items []models.Holiday
+	if err := q.Order("date asc").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load holidays"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// POST /api/holidays (HR only)
+func CreateHoliday(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage holidays"})
+		return
+	}
+
+	var req HolidayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, expected YYYY-MM-DD"})
+		return
+	}
+
+	holiday := models.Holiday{
+		Name:        name,
+		Date:        date,
+		Location:    strings.TrimSpace(req.Location),
+		Description: req.Description,
+	}
+	if err := config.DB.Create(&holiday).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create holiday"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": holiday})
+}
+
+// PUT /api/holidays/:id (HR only)
+func UpdateHoliday(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage holidays"})
+		return
+	}
+
+	id := c.Param("id")
+	var in struct {
+		Name        *string `json:"name"`
+		Date        *string `json:"date"`
+		Location    *string `json:"location"`
+		Description *string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+
+	updates := map[string]any{}
+	if in.Name != nil {
+		name := strings.TrimSpace(*in.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+			return
+		}
+		updates["name"] = name
+	}
+	if in.Date != nil {
+		date, err := time.Parse("2006-01-02", *in.Date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, expected YYYY-MM-DD"})
+			return
+		}
+		updates["date"] = date
+	}
+	if in.Location != nil {
+		updates["location"] = strings.TrimSpace(*in.Location)
+	}
+	if in.Description != nil {
+		updates["description"] = *in.Description
+	}
+
+	tx := config.DB.Model(&models.Holiday{}).Where("id = ?", id).Updates(updates)
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "updated"})
+}
+
+// DELETE /api/holidays/:id (HR only)
+func DeleteHoliday(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage holidays"})
+		return
+	}
+
+	tx := config.DB.Delete(&models.Holiday{}, c.Param("id"))
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// employeeLocation returns the office location on the user's employee record,
+// or "" when the user has no employee profile.
+func employeeLocation(userID uint) string {
+	var location string
+	config.DB.Table("employees").Select("location").Where("user_id = ?", userID).Limit(1).Scan(&location)
+	return location
+}
+
+// company-wide holidays (empty location) apply everywhere
+func scopeHolidaysToLocation(q *gorm.DB, location string) *gorm.DB {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return q.Where("location = ''")
+	}
+	return q.Where("location = '' OR LOWER(location) = LOWER(?)", location)
+}
+
+// holidaysBetween returns the holiday dates ("YYYY-MM-DD") observed at a location in [start, end]
+func holidaysBetween(location string, start, end time.Time) map[string]bool {
+	var dates []time.Time
+	scopeHolidaysToLocation(config.DB.Model(&models.Holiday{}), location).
+		Where("date >= ? AND date <= ?", start, end).
+		Pluck("date", &dates)
+
+	set := make(map[string]bool, len(dates))
+	for _, d := range dates {
+		set[d.Format("2006-01-02")] = true
+	}
+	return set
+}
+
+// holidayNamesBetween is like holidaysBetween but maps each date to the holiday's name
+func holidayNamesBetween(location string, start, end time.Time) map[string]string {
+	var items []models.Holiday
+	scopeHolidaysToLocation(config.DB.Model(&models.Holiday{}), location).
+		Where("date >= ? AND date <= ?", start, end).
+		Order("date asc").
+		Find(&items)
+
+	names := make(map[string]string, len(items))
+	for _, h := range items {
+		names[h.Date.Format("2006-01-02")] = h.Name
+	}
+	return names
+}
+
+// kinds of day in a leave breakdown
+const (
+	dayWorking = "working"
+	dayWeekend = "weekend"
+	dayHoliday = "holiday"
+)
+
+// LeaveDay is one calendar day of a leave and what it is charged.
+type LeaveDay struct {
+	Date    string  `json:"date"` // "YYYY-MM-DD"
+	Kind    string  `json:"kind"` // working / weekend / holiday
+	Holiday string  `json:"holiday,omitempty"`
+	Charged float64 `json:"charged"`
+	Note    string  `json:"note,omitempty"` // why a weekend or holiday is charged
+}
+
+// leaveDayBreakdown lists every day of [start, end] with what the policy
+// charges for it. Working days always count; weekends and holidays (of the
+// user's office) count when the policy says so, or, with the sandwich rule,
+// when they fall between two charged days of the same leave.
+func leaveDayBreakdown(userID uint, start, end time.Time, policy *models.LeavePolicy) []LeaveDay {
+	var rules models.LeavePolicy
+	if policy != nil {
+		rules = *policy
+	}
+	holidays := holidayNamesBetween(employeeLocation(userID), start, end)
+
+	days := []LeaveDay{}
+	first, last := -1, -1
+	for cur := start; !cur.After(end); cur = cur.AddDate(0, 0, 1) {
+		day := LeaveDay{Date: cur.Format("2006-01-02"), Kind: dayWorking}
+		if name, ok := holidays[day.Date]; ok {
+			day.Kind, day.Holiday = dayHoliday, name
+		} else if wd := cur.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			day.Kind = dayWeekend
+		}
+
+		switch {
+		case day.Kind == dayWorking:
+			day.Charged = 1
+		case day.Kind == dayWeekend && rules.CountWeekends:
+			day.Charged, day.Note = 1, "weekends count for this leave type"
+		case day.Kind == dayHoliday && rules.CountHolidays:
+			day.Charged, day.Note = 1, "holidays count for this leave type"
+		}
+		if day.Charged > 0 {
+			if first < 0 {
+				first = len(days)
+			}
+			last = len(days)
+		}
+		days = append(days, day)
+	}
+
+	if rules.SandwichRule && first >= 0 {
+		for i := first + 1; i < last; i++ {
+			if days[i].Charged == 0 {
+				days[i].Charged, days[i].Note = 1, "sandwiched between leave days"
+			}
+		}
+	}
+	return days
+}
+
+// leaveDaysForUser counts the days a user would be charged for [start, end]
+// under the leave policy; see leaveDayBreakdown.
+func leaveDaysForUser(userID uint, start, end time.Time, policy *models.LeavePolicy) float64 {
+	total := 0.0
+	for _, d := range leaveDayBreakdown(userID, start, end, policy) {
+		total += d.Charged
+	}
+	return total
+}
+
+// touchingLeaves returns the user's pending and approved full-day leaves of
+// the type that run into [start, end] with only weekends and holidays between,
+// following each one on to the next, in date order.
+func touchingLeaves(userID uint, leaveType string, start, end time.Time) ([]models.Leave, error) {
+	var leaves []models.Leave
+	if err := config.DB.Where("user_id = ? AND type = ? AND status IN ? AND portion IN ?",
+		userID, leaveType, []string{"pending", "approved"}, []string{portionFull, ""}).
+		Order("start_date asc").
+		Find(&leaves).Error; err != nil {
+		return nil, err
+	}
+	if len(leaves) == 0 {
+		return nil, nil
+	}
+
+	byStart := map[string]int{}
+	byEnd := map[string]int{}
+	for i, l := range leaves {
+		byStart[l.StartDate.Format("2006-01-02")] = i
+		byEnd[l.EndDate.Format("2006-01-02")] = i
+	}
+	holidays := holidaysBetween(employeeLocation(userID),
+		minTime(leaves[0].StartDate, start).AddDate(0, 0, -7), maxTime(leaves[len(leaves)-1].EndDate, end).AddDate(0, 0, 7))
+	dayOff := func(d time.Time) bool {
+		wd := d.Weekday()
+		return wd == time.Saturday || wd == time.Sunday || holidays[d.Format("2006-01-02")]
+	}
+
+	var before, after []models.Leave
+	for cur := start.AddDate(0, 0, -1); ; cur = cur.AddDate(0, 0, -1) {
+		if i, ok := byEnd[cur.Format("2006-01-02")]; ok {
+			before = append([]models.Leave{leaves[i]}, before...)
+			cur = leaves[i].StartDate
+			continue
+		}
+		if !dayOff(cur) {
+			break
+		}
+	}
+	for cur := end.AddDate(0, 0, 1); ; cur = cur.AddDate(0, 0, 1) {
+		if i, ok := byStart[cur.Format("2006-01-02")]; ok {
+			after = append(after, leaves[i])
+			cur = leaves[i].EndDate
+			continue
+		}
+		if !dayOff(cur) {
+			break
+		}
+	}
+	return append(before, after...), nil
+}
+
+// requestDayBreakdown is leaveDayBreakdown for a new full-day request read
+// together with the leaves it touches (see touchingLeaves), so the sandwich
+// rule also charges the days off between them and the request. The charged
+// days off before start or after end are listed with the request's days.
+func requestDayBreakdown(userID uint, leaveType string, start, end time.Time, policy *models.LeavePolicy) ([]LeaveDay, []models.Leave, error) {
+	touching, err := touchingLeaves(userID, leaveType, start, end)
+	if err != nil || len(touching) == 0 {
+		return leaveDayBreakdown(userID, start, end, policy), nil, err
+	}
+
+	// the breakdown spans the whole run so the sandwich rule sees the touching
+	// leaves' days; the request owns the days off up to its nearest neighbours
+	from, to := start, end
+	ownFrom, ownTo := start, end
+	seenAfter := false
+	for i, l := range touching {
+		if l.EndDate.Before(start) {
+			if i == 0 {
+				from = l.StartDate
+			}
+			ownFrom = l.EndDate.AddDate(0, 0, 1)
+			continue
+		}
+		if !seenAfter {
+			ownTo, seenAfter = l.StartDate.AddDate(0, 0, -1), true
+		}
+		to = l.EndDate
+	}
+
+	first, last := ownFrom.Format("2006-01-02"), ownTo.Format("2006-01-02")
+	reqFirst, reqLast := start.Format("2006-01-02"), end.Format("2006-01-02")
+	days := []LeaveDay{}
+	for _, d := range leaveDayBreakdown(userID, from, to, policy) {
+		if d.Date < first || d.Date > last {
+			continue
+		}
+		// days off beside the request only belong to it when charged
+		if (d.Date < reqFirst || d.Date > reqLast) && d.Charged == 0 {
+			continue
+		}
+		days = append(days, d)
+	}
+	return days, touching, nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
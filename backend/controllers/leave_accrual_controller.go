@@ -0,0 +1,306 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccrualPeriod is one row of a user's accrual schedule.
+type AccrualPeriod struct {
+	PeriodStart time.Time  `json:"period_start"`
+	PeriodEnd   time.Time  `json:"period_end"`
+	Days        float64    `json:"days"`
+	Cumulative  float64    `json:"cumulative"`
+	Posted      bool       `json:"posted"`
+	PostedAt    *time.Time `json:"posted_at"`
+}
+
+// AccrualScheduleResponse is the accrual schedule of one leave type for a year.
+type AccrualScheduleResponse struct {
+	Type              string          `json:"type"`
+	Frequency         string          `json:"frequency"`
+	AnnualEntitlement int             `json:"annual_entitlement"`
+	JoinedOn          time.Time       `json:"joined_on"`
+	ProRated          bool            `json:"pro_rated"`
+	Periods           []AccrualPeriod `json:"periods"`
+}
+
+func normalizeAccrualFrequency(f string) string {
+	f = strings.ToLower(strings.TrimSpace(f))
+	if f == "" {
+		return "annual"
+	}
+	return f
+}
+
+// accrualPeriodsPerYear returns how many credits a frequency posts per year (0 if unknown).
+func accrualPeriodsPerYear(frequency string) int {
+	switch normalizeAccrualFrequency(frequency) {
+	case "annual":
+		return 1
+	case "quarterly":
+		return 4
+	case "monthly":
+		return 12
+	default:
+		return 0
+	}
+}
+
+// joiningDate is the employee record's creation date, falling back to the user's.
+func joiningDate(userID uint) time.Time {
+	var joined time.Time
+	config.DB.Table("employees").Select("created_at").Where("user_id = ?", userID).Limit(1).Scan(&joined)
+	if joined.IsZero() {
+		config.DB.Table("users").Select("created_at").Where("id = ?", userID).Limit(1).Scan(&joined)
+	}
+	return joined
+}
+
+// eligibleMonthsThrough counts the months of the year, up to and including
+// month, that an employee who joined on the given date is credited for.
+// A month counts if the employee joined on or before its 15th.
+func eligibleMonthsThrough(year, month int, joined time.Time) int {
+	n := 0
+	for m := 1; m <= month; m++ {
+		cutoff := time.Date(year, time.Month(m), 15, 23, 59, 59, 0, time.UTC)
+		if joined.IsZero() || !joined.After(cutoff) {
+			n++
+		}
+	}
+	return n
+}
+
+// buildAccrualSchedule splits a policy's annual entitlement into accrual
+// periods, pro-rated from the joining date. Each period carries the
+// cumulative amount (rounded to two decimals) minus what earlier periods
+// credited, so the periods always add up to the pro-rated entitlement.
+func buildAccrualSchedule(policy *models.LeavePolicy, year int, joined time.Time) []AccrualPeriod {
+	periods := accrualPeriodsPerYear(policy.AccrualFrequency)
+	if periods == 0 {
+		return nil
+	}
+	months := 12 / periods
+
+	var schedule []AccrualPeriod
+	prev := 0.0
+	for k := 0; k < periods; k++ {
+		firstMonth := k*months + 1
+		lastMonth := firstMonth + months - 1
+		start := time.Date(year, time.Month(firstMonth), 1, 0, 0, 0, 0, time.UTC)
+		end := time.Date(year, time.Month(lastMonth)+1, 0, 0, 0, 0, 0, time.UTC)
+
+		cumulative := roundDays(float64(policy.AnnualEntitlement*eligibleMonthsThrough(year, lastMonth, joined)) / 12)
+		schedule = append(schedule, AccrualPeriod{
+			PeriodStart: start,
+			PeriodEnd:   end,
+			Days:        roundDays(cumulative - prev),
+			Cumulative:  cumulative,
+		})
+		prev = cumulative
+	}
+	return schedule
+}
+
+// accrueAllocation posts every accrual period of the allocation that is due by
+// asOf to the leave ledger. Annual grants are always due so
+// that leave can be booked ahead for the whole year; periodic accruals are due
+// from the first day of their period. Safe to run repeatedly: the period
+// markers and the credits they stand for are written in one transaction under
+// the allocation's row lock, so a period is never marked without its credit.
+func accrueAllocation(db *gorm.DB, alloc *models.LeaveAllocation, asOf time.Time) error {
+	policy, err := resolveLeavePolicy(loadLeaveProfile(alloc.UserID), alloc.Type)
+	if err != nil || policy == nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		locked, err := lockAllocation(tx, alloc.UserID, alloc.Year, alloc.Type)
+		if err != nil {
+			return err
+		}
+		*alloc = *locked
+		return postDueAccruals(tx, alloc, policy, asOf)
+	})
+}
+
+// postDueAccruals is accrueAllocation for an allocation already locked in db.
+func postDueAccruals(db *gorm.DB, alloc *models.LeaveAllocation, policy *models.LeavePolicy, asOf time.Time) error {
+	var posted []models.LeaveAccrual
+	if err := db.Where("user_id = ? AND year = ? AND type = ?", alloc.UserID, alloc.Year, alloc.Type).
+		Find(&posted).Error; err != nil {
+		return err
+	}
+	postedOn := map[string]bool{}
+	for _, p := range posted {
+		postedOn[p.PeriodStart.Format("2006-01-02")] = true
+	}
+
+	schedule := buildAccrualSchedule(policy, alloc.Year, joiningDate(alloc.UserID))
+	record := func(period AccrualPeriod) error {
+		entry := models.LeaveAccrual{
+			UserID:      alloc.UserID,
+			Year:        alloc.Year,
+			Type:        alloc.Type,
+			PeriodStart: period.PeriodStart,
+			Days:        period.Days,
+			PostedAt:    time.Now(),
+		}
+		postedOn[period.PeriodStart.Format("2006-01-02")] = true
+		return db.Create(&entry).Error
+	}
+
+	// allocations granted before accruals existed already hold their
+	// entitlement: mark the periods that total covers as posted without crediting them again
+	if len(posted) == 0 && alloc.Total > 0 {
+		for _, period := range schedule {
+			if period.Cumulative > alloc.Total {
+				break
+			}
+			if err := record(period); err != nil {
+				return err
+			}
+		}
+	}
+
+	annual := normalizeAccrualFrequency(policy.AccrualFrequency) == "annual"
+	kind := models.LeaveTxnAccrual
+	if annual {
+		kind = models.LeaveTxnGrant
+	}
+	for _, period := range schedule {
+		if !annual && period.PeriodStart.After(asOf) {
+			break
+		}
+		// zero-day periods are recorded too, so the allocation is never mistaken for a pre-accrual one
+		if postedOn[period.PeriodStart.Format("2006-01-02")] {
+			continue
+		}
+		if err := record(period); err != nil {
+			return err
+		}
+		if period.Days == 0 {
+			continue
+		}
+		if err := postLeaveTransaction(db, alloc, models.LeaveTransaction{
+			Kind:   kind,
+			Days:   period.Days,
+			Reason: fmt.Sprintf("%s accrual from %s", normalizeAccrualFrequency(policy.AccrualFrequency), period.PeriodStart.Format("2006-01-02")),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunLeaveAccruals posts due accruals for every employee and every periodic
+// leave type they are eligible for. Called by the scheduler.
+func RunLeaveAccruals(asOf time.Time) error {
+	var userIDs []uint
+	if err := config.DB.Table("employees").Distinct("user_id").Pluck("user_id", &userIDs).Error; err != nil {
+		return err
+	}
+
+	year := asOf.Year()
+	for _, userID := range userIDs {
+		eligible, err := eligibleLeaveTypes(loadLeaveProfile(userID))
+		if err != nil {
+			return err
+		}
+		for _, et := range eligible {
+			if normalizeAccrualFrequency(et.Policy.AccrualFrequency) == "annual" {
+				continue // granted when the allocation is first created
+			}
+			alloc, err := getOrCreateAllocation(userID, year, et.Code)
+			if err != nil {
+				return fmt.Errorf("allocation for user %d (%s): %w", userID, et.Code, err)
+			}
+			if err := accrueAllocation(config.DB, alloc, asOf); err != nil {
+				log.Printf("accrual for user %d (%s) failed: %v", userID, et.Code, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GET /api/leaves/accruals?user_id=&year=&type=
+// Accrual schedule per leave type. Employees see their own; managers their
+// direct reports; HR anyone.
+func GetAccrualSchedule(c *gin.Context) {
+	callerID := c.GetUint("userID")
+	role := c.GetString("role")
+
+	targetID := callerID
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+			return
+		}
+		targetID = uint(id)
+	}
+	if targetID != callerID && !resolveTeamScope(callerID, role).handles(targetID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this user's accruals"})
+		return
+	}
+
+	year, err := strconv.Atoi(c.DefaultQuery("year", strconv.Itoa(time.Now().Year())))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+		return
+	}
+
+	eligible, err := eligibleLeaveTypes(loadLeaveProfile(targetID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load leave policies"})
+		return
+	}
+
+	var posted []models.LeaveAccrual
+	if err := config.DB.Where("user_id = ? AND year = ?", targetID, year).Find(&posted).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load accruals"})
+		return
+	}
+	postedAt := map[string]time.Time{}
+	for _, p := range posted {
+		postedAt[p.Type+"|"+p.PeriodStart.Format("2006-01-02")] = p.PostedAt
+	}
+
+	joined := joiningDate(targetID)
+	filter := strings.ToLower(c.Query("type"))
+
+	result := []AccrualScheduleResponse{}
+	for _, et := range eligible {
+		if filter != "" && et.Code != filter {
+			continue
+		}
+		policy := et.Policy
+		schedule := buildAccrualSchedule(&policy, year, joined)
+		for i := range schedule {
+			if at, ok := postedAt[et.Code+"|"+schedule[i].PeriodStart.Format("2006-01-02")]; ok {
+				schedule[i].Posted = true
+				schedule[i].PostedAt = &at
+			}
+		}
+		result = append(result, AccrualScheduleResponse{
+			Type:              et.Code,
+			Frequency:         normalizeAccrualFrequency(policy.AccrualFrequency),
+			AnnualEntitlement: policy.AnnualEntitlement,
+			JoinedOn:          joined,
+			ProRated:          eligibleMonthsThrough(year, 12, joined) < 12,
+			Periods:           schedule,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"peoplesoft/models"
+	"testing"
+	"time"
+)
+
+func ymd(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestEligibleMonthsThrough(t *testing.T) {
+	tests := []struct {
+		name   string
+		month  int
+		joined time.Time
+		want   int
+	}{
+		{"unknown joining date counts every month", 12, time.Time{}, 12},
+		{"joined in an earlier year", 6, ymd(2023, time.July, 1), 6},
+		{"joined on the 15th counts that month", 12, ymd(2025, time.April, 15), 9},
+		{"joined after the 15th starts next month", 12, ymd(2025, time.April, 16), 8},
+		{"not joined yet by the month", 3, ymd(2025, time.April, 1), 0},
+		{"joined in a later year", 12, ymd(2026, time.January, 1), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := eligibleMonthsThrough(2025, tt.month, tt.joined); got != tt.want {
+				t.Errorf("eligibleMonthsThrough(2025, %d, %v) = %d, want %d", tt.month, tt.joined.Format("2006-01-02"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildAccrualSchedule(t *testing.T) {
+	tests := []struct {
+		name        string
+		frequency   string
+		entitlement int
+		joined      time.Time
+		wantDays    []float64
+	}{
+		{"annual", "annual", 12, time.Time{}, []float64{12}},
+		{"empty frequency is annual", "", 15, time.Time{}, []float64{15}},
+		{"quarterly", "Quarterly", 10, time.Time{}, []float64{2.5, 2.5, 2.5, 2.5}},
+		{"monthly", "monthly", 12, time.Time{}, []float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}},
+		{
+			"monthly rounding adds up to the entitlement", "monthly", 10, time.Time{},
+			[]float64{0.83, 0.84, 0.83, 0.83, 0.84, 0.83, 0.83, 0.84, 0.83, 0.83, 0.84, 0.83},
+		},
+		{"monthly pro-rated from joining", "monthly", 12, ymd(2025, time.April, 20), []float64{0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1}},
+		{"quarterly pro-rated from joining", "quarterly", 12, ymd(2025, time.February, 1), []float64{2, 3, 3, 3}},
+		{"annual pro-rated from joining", "annual", 12, ymd(2025, time.July, 10), []float64{6}},
+		{"unknown frequency", "weekly", 12, time.Time{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &models.LeavePolicy{AccrualFrequency: tt.frequency, AnnualEntitlement: tt.entitlement}
+			schedule := buildAccrualSchedule(policy, 2025, tt.joined)
+			if len(schedule) != len(tt.wantDays) {
+				t.Fatalf("got %d periods, want %d", len(schedule), len(tt.wantDays))
+			}
+
+			total := 0.0
+			for i, p := range schedule {
+				if p.Days != tt.wantDays[i] {
+					t.Errorf("period %d: days = %v, want %v", i, p.Days, tt.wantDays[i])
+				}
+				total = roundDays(total + p.Days)
+				if p.Cumulative != total {
+					t.Errorf("period %d: cumulative = %v, want %v", i, p.Cumulative, total)
+				}
+				if i == 0 && !p.PeriodStart.Equal(ymd(2025, time.January, 1)) {
+					t.Errorf("first period starts %v, want 2025-01-01", p.PeriodStart)
+				}
+				if i > 0 && !p.PeriodStart.Equal(schedule[i-1].PeriodEnd.AddDate(0, 0, 1)) {
+					t.Errorf("period %d starts %v, not the day after the previous period", i, p.PeriodStart)
+				}
+			}
+			if n := len(schedule); n > 0 && !schedule[n-1].PeriodEnd.Equal(ymd(2025, time.December, 31)) {
+				t.Errorf("last period ends %v, want 2025-12-31", schedule[n-1].PeriodEnd)
+			}
+		})
+	}
+}
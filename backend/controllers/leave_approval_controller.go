@@ -0,0 +1,570 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// approval chain steps
+const (
+	approverManager = "manager"
+	approverHR      = "hr"
+)
+
+// chain used when no LeaveApprovalRule matches
+const defaultApprovalChain = approverManager
+
+type ApprovalRuleRequest struct {
+	LeaveType string  `json:"leave_type"`
+	MinDays   float64 `json:"min_days"`
+	Chain     string  `json:"chain"`
+}
+
+type DelegationRequest struct {
+	DelegatorID uint   `json:"delegator_id"` // HR only; defaults to the caller
+	DelegateID  uint   `json:"delegate_id"`
+	StartDate   string `json:"start_date"` // "YYYY-MM-DD"
+	EndDate     string `json:"end_date"`   // "YYYY-MM-DD"
+}
+
+// ApprovalHistoryItem is a LeaveApproval with the names of the people involved.
+type ApprovalHistoryItem struct {
+	models.LeaveApproval
+	ActorName      string  `json:"actor_name"`
+	OnBehalfOfName *string `json:"on_behalf_of_name"`
+}
+
+func splitApprovalChain(chain string) []string {
+	if strings.TrimSpace(chain) == "" {
+		return []string{defaultApprovalChain}
+	}
+	return strings.Split(chain, ",")
+}
+
+// normalizeApprovalChain validates a chain such as "Manager, HR" and returns
+// it in stored form ("manager,hr").
+func normalizeApprovalChain(chain string) (string, error) {
+	var steps []string
+	seen := map[string]bool{}
+	for _, s := range strings.Split(chain, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s != approverManager && s != approverHR {
+			return "", errors.New("chain steps must be manager or hr")
+		}
+		if seen[s] {
+			return "", errors.New("chain cannot repeat a step")
+		}
+		seen[s] = true
+		steps = append(steps, s)
+	}
+	return strings.Join(steps, ","), nil
+}
+
+// approvalChainFor picks the chain for a leave of the given type and length.
+func approvalChainFor(leaveType string, days float64) (string, error) {
+	var rule models.LeaveApprovalRule
+	err := config.DB.
+		Where("(leave_type = '' OR leave_type = ?) AND min_days <= ?", leaveType, days).
+		Order("min_days desc, leave_type desc, id asc").
+		First(&rule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return defaultApprovalChain, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return rule.Chain, nil
+}
+
+// leaveManagerUserID is the user id of userID's direct manager (0 if none).
+func leaveManagerUserID(userID uint) uint {
+	var managerID uint
+	config.DB.Table("employees e").
+		Select("me.user_id").
+		Joins("JOIN employees me ON me.id = e.manager_id").
+		Where("e.user_id = ?", userID).
+		Limit(1).
+		Scan(&managerID)
+	return managerID
+}
+
+// hasActiveDelegation reports whether delegator has handed approvals to delegate on the given day.
+func hasActiveDelegation(delegatorID, delegateID uint, on time.Time) bool {
+	var count int64
+	config.DB.Model(&models.LeaveApprovalDelegation{}).
+		Where("delegator_id = ? AND delegate_id = ? AND start_date <= ? AND end_date >= ?", delegatorID, delegateID, on, on).
+		Count(&count)
+	return count > 0
+}
+
+// reportsOfApprover is a subquery of the user ids whose requests the approver
+// handles: their direct reports and, while a delegation to them is active,
+// the direct reports of the delegator.
+func reportsOfApprover(approverID uint) *gorm.DB {
+	today := time.Now().Truncate(24 * time.Hour)
+	delegators := config.DB.Model(&models.LeaveApprovalDelegation{}).
+		Select("delegator_id").
+		Where("delegate_id = ? AND start_date <= ? AND end_date >= ?", approverID, today, today)
+	return config.DB.Table("employees e").
+		Select("e.user_id").
+		Joins("JOIN employees me ON me.id = e.manager_id").
+		Where("me.user_id = ? OR me.user_id IN (?)", approverID, delegators)
+}
+
+// currentApprovalStep returns the role the leave is waiting on. A manager
+// step for an employee without a manager falls through to HR.
+func currentApprovalStep(leave models.Leave) string {
+	return approvalStepAt(leave, leave.ApprovalStep)
+}
+
+// approvalStepAt returns the role that handles step i of the leave's chain.
+func approvalStepAt(leave models.Leave, i int) string {
+	steps := splitApprovalChain(leave.ApprovalChain)
+	step := steps[min(i, len(steps)-1)]
+	if step == approverManager {
+		if mgr := leaveManagerUserID(leave.UserID); mgr == 0 || mgr == leave.UserID {
+			return approverHR
+		}
+	}
+	return step
+}
+
+// canActOnLeave decides whether the actor may approve or reject the leave's
+// current step. The direct manager (or someone they delegated to, or the
+// person an overdue step was escalated to) handles manager steps, HR handles
+// HR steps and may stand in for a manager. Nobody acts on their own leave or
+// on more than one step of the same leave; HR steps that follow a step HR
+// approved are approved with it (see mergedApprovalSteps). onBehalfOf is set
+// when the actor stands in for the manager.
+func canActOnLeave(actorID uint, actorRole string, leave models.Leave) (onBehalfOf *uint, ok bool) {
+	if actorID == leave.UserID {
+		return nil, false
+	}
+
+	var acted int64
+	config.DB.Model(&models.LeaveApproval{}).Where("leave_id = ? AND actor_id = ?", leave.ID, actorID).Count(&acted)
+	if acted > 0 {
+		return nil, false
+	}
+
+	switch currentApprovalStep(leave) {
+	case approverManager:
+		mgr := leaveManagerUserID(leave.UserID)
+		if actorID == mgr {
+			return nil, true
+		}
+		if hasActiveDelegation(mgr, actorID, time.Now().Truncate(24*time.Hour)) {
+			return &mgr, true
+		}
+		if isEscalatedTo(leave, actorID) {
+			return &mgr, true
+		}
+		return nil, actorRole == "hr"
+	case approverHR:
+		return nil, actorRole == "hr"
+	}
+	return nil, false
+}
+
+// mergedApprovalSteps returns the last step an approval of the leave's
+// current step by someone with the given role covers. When HR approves a
+// step, the HR steps straight after it resolve to the same approver, who may
+// not act twice on one leave, so they are approved together: a manager,hr
+// chain for an employee without a manager, or with HR standing in for the
+// manager, needs a single HR approval.
+func mergedApprovalSteps(leave models.Leave, role string) int {
+	last := leave.ApprovalStep
+	if role != "hr" {
+		return last
+	}
+	steps := splitApprovalChain(leave.ApprovalChain)
+	for last+1 < len(steps) && approvalStepAt(leave, last+1) == approverHR {
+		last++
+	}
+	return last
+}
+
+// ensureApprovalChain fills in the chain of a leave applied for before approval chains existed.
+func ensureApprovalChain(db *gorm.DB, leave *models.Leave) error {
+	if leave.ApprovalChain != "" {
+		return nil
+	}
+	chain, err := approvalChainFor(leave.Type, leave.Days)
+	if err != nil {
+		return err
+	}
+	leave.ApprovalChain = chain
+	return db.Model(leave).Update("approval_chain", chain).Error
+}
+
+// decision comment, optional on approve and reject
+func bindDecisionComment(c *gin.Context) string {
+	var in struct {
+		Comment string `json:"comment"`
+	}
+	if c.Request.ContentLength > 0 {
+		_ = c.ShouldBindJSON(&in)
+	}
+	return strings.TrimSpace(in.Comment)
+}
+
+// maxBulkDecisions caps how many leaves one bulk request may decide.
+const maxBulkDecisions = 100
+
+type BulkDecisionRequest struct {
+	IDs      []uint `json:"ids"`
+	Decision string `json:"decision"` // approve | reject
+	Comment  string `json:"comment"`
+	Force    bool   `json:"force"` // approve despite team absence conflicts
+}
+
+// BulkDecisionResult is the outcome for one leave of a bulk decision.
+type BulkDecisionResult struct {
+	ID     uint  `json:"id"`
+	OK     bool  `json:"ok"`
+	Status int   `json:"status"` // the HTTP status the single-leave endpoint would have returned
+	Body   gin.H `json:"body"`
+}
+
+// POST /api/leaves/bulk-decision
+// Body: {"ids": [12, 15], "decision": "approve", "comment": "...", "force": false}
+// Decides each leave as PUT /leaves/:id/approve or /reject would, each in its
+// own transaction, so one failure does not hold back the rest.
+func BulkLeaveDecision(c *gin.Context) {
+	userID := c.GetUint("userID")
+	role := c.GetString("role")
+
+	var req BulkDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	decision := strings.ToLower(strings.TrimSpace(req.Decision))
+	if decision != "approve" && decision != "reject" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "decision must be approve or reject"})
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxBulkDecisions {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("between 1 and %d leave ids are required", maxBulkDecisions)})
+		return
+	}
+	comment := strings.TrimSpace(req.Comment)
+
+	results := make([]BulkDecisionResult, 0, len(req.IDs))
+	seen := map[uint]bool{}
+	succeeded := 0
+	for _, id := range req.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		var resp gin.H
+		var lerr *leaveError
+		if decision == "approve" {
+			resp, lerr = approveLeave(strconv.FormatUint(uint64(id), 10), userID, role, comment, req.Force)
+		} else {
+			resp, lerr = rejectLeave(strconv.FormatUint(uint64(id), 10), userID, role, comment)
+		}
+
+		if lerr != nil {
+			results = append(results, BulkDecisionResult{ID: id, Status: lerr.Status, Body: lerr.Body})
+			continue
+		}
+		succeeded++
+		results = append(results, BulkDecisionResult{ID: id, OK: true, Status: http.StatusOK, Body: resp})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      results,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+	})
+}
+
+// GET /api/leaves/approvals/pending
+// Pending leaves whose current step the caller can decide on, including ones
+// delegated to them.
+func ListPendingApprovals(c *gin.Context) {
+	userID := c.GetUint("userID")
+	role := c.GetString("role")
+
+	var leaves []models.Leave
+	q := config.DB.Where("status = ?", "pending")
+	if role != "hr" {
+		q = q.Where("user_id IN (?)", reportsOfApprover(userID))
+	}
+	if err := q.Order("start_date asc").Find(&leaves).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pending approvals"})
+		return
+	}
+
+	items := []gin.H{}
+	for _, l := range leaves {
+		if err := ensureApprovalChain(config.DB, &l); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load approval chain"})
+			return
+		}
+		onBehalfOf, ok := canActOnLeave(userID, role, l)
+		if !ok {
+			continue
+		}
+		items = append(items, gin.H{
+			"leave":        l,
+			"awaiting":     currentApprovalStep(l),
+			"on_behalf_of": onBehalfOf,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// GET /api/leaves/:id/approvals
+// Approval chain and decision history of a leave. Visible to the employee,
+// HR, their manager and anyone who acted on or can act on it.
+func GetLeaveApprovals(c *gin.Context) {
+	userID := c.GetUint("userID")
+	role := c.GetString("role")
+
+	var leave models.Leave
+	if err := config.DB.First(&leave, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "leave not found"})
+		return
+	}
+
+	var history []ApprovalHistoryItem
+	if err := config.DB.Table("leave_approvals a").
+		Select("a.*, COALESCE(u.name, 'system') AS actor_name, bu.name AS on_behalf_of_name").
+		Joins("LEFT JOIN users u ON u.id = a.actor_id").
+		Joins("LEFT JOIN users bu ON bu.id = a.on_behalf_of").
+		Where("a.leave_id = ?", leave.ID).
+		Order("a.created_at asc, a.id asc").
+		Scan(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load approval history"})
+		return
+	}
+
+	allowed := leave.UserID == userID || resolveTeamScope(userID, role).handles(leave.UserID)
+	for _, h := range history {
+		allowed = allowed || h.ActorID == userID
+	}
+	if !allowed && leave.Status == "pending" {
+		_, allowed = canActOnLeave(userID, role, leave)
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this leave"})
+		return
+	}
+
+	chain := splitApprovalChain(leave.ApprovalChain)
+	resp := gin.H{
+		"leave_id": leave.ID,
+		"status":   leave.Status,
+		"chain":    chain,
+		"step":     leave.ApprovalStep,
+		"history":  history,
+	}
+	if leave.Status == "pending" {
+		resp["awaiting"] = currentApprovalStep(leave)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": resp})
+}
+
+// GET /api/leave-approval-rules
+func ListApprovalRules(c *gin.Context) {
+	var items []models.LeaveApprovalRule
+	if err := config.DB.Order("leave_type asc, min_days asc").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load approval rules"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items, "default_chain": defaultApprovalChain})
+}
+
+func approvalRuleFromRequest(c *gin.Context) (*models.LeaveApprovalRule, bool) {
+	var req ApprovalRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return nil, false
+	}
+	chain, err := normalizeApprovalChain(req.Chain)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if req.MinDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_days cannot be negative"})
+		return nil, false
+	}
+	return &models.LeaveApprovalRule{
+		LeaveType: strings.ToLower(strings.TrimSpace(req.LeaveType)),
+		MinDays:   roundDays(req.MinDays),
+		Chain:     chain,
+	}, true
+}
+
+// POST /api/leave-approval-rules (HR only)
+// Body: {"leave_type": "vacation", "min_days": 5, "chain": "manager,hr"}
+func CreateApprovalRule(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage approval rules"})
+		return
+	}
+	rule, ok := approvalRuleFromRequest(c)
+	if !ok {
+		return
+	}
+	if err := config.DB.Create(rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create approval rule"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": rule})
+}
+
+// PUT /api/leave-approval-rules/:id (HR only)
+// Leaves already applied for keep the chain they were submitted with.
+func UpdateApprovalRule(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage approval rules"})
+		return
+	}
+	var existing models.LeaveApprovalRule
+	if err := config.DB.First(&existing, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "approval rule not found"})
+		return
+	}
+	rule, ok := approvalRuleFromRequest(c)
+	if !ok {
+		return
+	}
+	rule.ID, rule.CreatedAt = existing.ID, existing.CreatedAt
+	if err := config.DB.Save(rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update approval rule"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rule})
+}
+
+// DELETE /api/leave-approval-rules/:id (HR only)
+func DeleteApprovalRule(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage approval rules"})
+		return
+	}
+	res := config.DB.Delete(&models.LeaveApprovalRule{}, c.Param("id"))
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete approval rule"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "approval rule not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// GET /api/leaves/delegations
+// Delegations the caller gave or received; HR sees all.
+func ListDelegations(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	q := config.DB.Model(&models.LeaveApprovalDelegation{})
+	if c.GetString("role") != "hr" {
+		q = q.Where("delegator_id = ? OR delegate_id = ?", userID, userID)
+	}
+
+	var items []models.LeaveApprovalDelegation
+	if err := q.Order("start_date desc").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load delegations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// POST /api/leaves/delegations (managers and HR)
+// Hands the caller's approvals to delegate_id for the date range, e.g. while
+// the caller is on leave. HR may create a delegation for another approver.
+func CreateDelegation(c *gin.Context) {
+	userID := c.GetUint("userID")
+	role := c.GetString("role")
+	if role != "manager" && role != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only managers or HR can delegate approvals"})
+		return
+	}
+
+	var req DelegationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	delegatorID := userID
+	if req.DelegatorID != 0 && req.DelegatorID != userID {
+		if role != "hr" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only HR can delegate on behalf of someone else"})
+			return
+		}
+		delegatorID = req.DelegatorID
+	}
+
+	start, err1 := time.Parse("2006-01-02", req.StartDate)
+	end, err2 := time.Parse("2006-01-02", req.EndDate)
+	if err1 != nil || err2 != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, expected YYYY-MM-DD"})
+		return
+	}
+	if end.Before(start) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end date cannot be before start date"})
+		return
+	}
+	if req.DelegateID == 0 || req.DelegateID == delegatorID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "delegate_id must be another user"})
+		return
+	}
+
+	var delegate models.User
+	if err := config.DB.First(&delegate, req.DelegateID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "delegate not found"})
+		return
+	}
+
+	d := models.LeaveApprovalDelegation{
+		DelegatorID: delegatorID,
+		DelegateID:  req.DelegateID,
+		StartDate:   start,
+		EndDate:     end,
+		CreatedBy:   userID,
+	}
+	if err := config.DB.Create(&d).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create delegation"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": d})
+}
+
+// DELETE /api/leaves/delegations/:id (delegator or HR)
+func DeleteDelegation(c *gin.Context) {
+	var d models.LeaveApprovalDelegation
+	if err := config.DB.First(&d, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "delegation not found"})
+		return
+	}
+	if d.DelegatorID != c.GetUint("userID") && c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the delegator or HR can remove a delegation"})
+		return
+	}
+	if err := config.DB.Delete(&d).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete delegation"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import "testing"
+
+func TestNormalizeApprovalChain(t *testing.T) {
+	tests := []struct {
+		chain   string
+		want    string
+		wantErr bool
+	}{
+		{chain: "manager", want: "manager"},
+		{chain: "Manager, HR", want: "manager,hr"},
+		{chain: " hr ,manager ", want: "hr,manager"},
+		{chain: "", wantErr: true},
+		{chain: "manager,", wantErr: true},
+		{chain: "manager,manager", wantErr: true},
+		{chain: "manager,director", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeApprovalChain(tt.chain)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("normalizeApprovalChain(%q) = %q, want an error", tt.chain, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("normalizeApprovalChain(%q) = %q, %v; want %q", tt.chain, got, err, tt.want)
+		}
+	}
+}
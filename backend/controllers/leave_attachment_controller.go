@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/storage"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxAttachmentSize      = 10 << 20 // per file
+	maxAttachmentsPerLeave = 5
+)
+
+// accepted attachment types (sniffed from the content) and their file extensions
+var attachmentTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+// uploadedFile is an attachment read from a multipart form and checked.
+type uploadedFile struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// readAttachments reads and validates the "file" parts of a multipart form.
+func readAttachments(form *multipart.Form) ([]uploadedFile, error) {
+	if form == nil {
+		return nil, nil
+	}
+	headers := form.File["file"]
+	if len(headers) > maxAttachmentsPerLeave {
+		return nil, fmt.Errorf("at most %d attachments per leave", maxAttachmentsPerLeave)
+	}
+
+	var files []uploadedFile
+	for _, h := range headers {
+		if h.Size > maxAttachmentSize {
+			return nil, fmt.Errorf("%s is larger than %d MB", h.Filename, maxAttachmentSize>>20)
+		}
+		f, err := h.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(f, maxAttachmentSize+1))
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxAttachmentSize {
+			return nil, fmt.Errorf("%s is larger than %d MB", h.Filename, maxAttachmentSize>>20)
+		}
+
+		contentType := http.DetectContentType(data)
+		if _, ok := attachmentTypes[contentType]; !ok {
+			return nil, fmt.Errorf("%s: only PDF, JPEG and PNG files are accepted", h.Filename)
+		}
+		files = append(files, uploadedFile{Name: filepath.Base(h.Filename), ContentType: contentType, Data: data})
+	}
+	return files, nil
+}
+
+// saveAttachments stores the files and records them against the leave.
+func saveAttachments(leave models.Leave, uploaderID uint, files []uploadedFile) ([]models.LeaveAttachment, error) {
+	var existing int64
+	config.DB.Model(&models.LeaveAttachment{}).Where("leave_id = ?", leave.ID).Count(&existing)
+	if int(existing)+len(files) > maxAttachmentsPerLeave {
+		return nil, fmt.Errorf("at most %d attachments per leave", maxAttachmentsPerLeave)
+	}
+
+	saved := []models.LeaveAttachment{}
+	for _, f := range files {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return saved, err
+		}
+		key := fmt.Sprintf("leaves/%d/%s%s", leave.ID, hex.EncodeToString(b), attachmentTypes[f.ContentType])
+		if err := storage.Default.Put(key, f.Data, f.ContentType); err != nil {
+			return saved, err
+		}
+
+		a := models.LeaveAttachment{
+			LeaveID:     leave.ID,
+			UploadedBy:  uploaderID,
+			FileName:    f.Name,
+			ContentType: f.ContentType,
+			Size:        int64(len(f.Data)),
+			StorageKey:  key,
+		}
+		if err := config.DB.Create(&a).Error; err != nil {
+			_ = storage.Default.Delete(key)
+			return saved, err
+		}
+		saved = append(saved, a)
+	}
+	return saved, nil
+}
+
+// canViewLeaveDetails is who may see a leave's documents: the employee,
+// their manager (or the manager's delegate) and HR.
+func canViewLeaveDetails(viewerID uint, role string, leave models.Leave) bool {
+	return viewerID == leave.UserID || resolveTeamScope(viewerID, role).handles(leave.UserID)
+}
+
+// missingRequiredDocument reports whether the leave's policy asks for a
+// supporting document that has not been uploaded yet.
+func missingRequiredDocument(leave models.Leave) (bool, error) {
+	policy, err := resolveLeavePolicy(loadLeaveProfile(leave.UserID), leave.Type)
+	if err != nil || policy == nil {
+		return false, err
+	}
+	days, err := leaveChargedDays(config.DB, leave)
+	if err != nil {
+		return false, err
+	}
+	if !documentRequired(policy, days) {
+		return false, nil
+	}
+	var count int64
+	if err := config.DB.Model(&models.LeaveAttachment{}).Where("leave_id = ?", leave.ID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
+
+// POST /api/leaves/:id/attachments (multipart, one or more "file" parts)
+// The employee (or HR) adds documents to a pending or approved leave.
+func UploadLeaveAttachments(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var leave models.Leave
+	if err := config.DB.First(&leave, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "leave not found"})
+		return
+	}
+	if leave.UserID != userID && c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only add documents to your own leave"})
+		return
+	}
+	if leave.Status != "pending" && leave.Status != "approved" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "documents can only be added to pending or approved leaves"})
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expected a multipart form with one or more file fields"})
+		return
+	}
+	files, err := readAttachments(form)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no file uploaded"})
+		return
+	}
+
+	saved, err := saveAttachments(leave, userID, files)
+	if err != nil {
+		log.Printf("saving attachments for leave %d: %v", leave.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store attachments", "saved": saved})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": saved})
+}
+
+// GET /api/leaves/:id/attachments
+func ListLeaveAttachments(c *gin.Context) {
+	var leave models.Leave
+	if err := config.DB.First(&leave, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "leave not found"})
+		return
+	}
+	if !canViewLeaveDetails(c.GetUint("userID"), c.GetString("role"), leave) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this leave's documents"})
+		return
+	}
+
+	var items []models.LeaveAttachment
+	if err := config.DB.Where("leave_id = ?", leave.ID).Order("created_at asc").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load attachments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// GET /api/leaves/attachments/:id
+// Streams the file to the employee, their manager or HR.
+func DownloadLeaveAttachment(c *gin.Context) {
+	var a models.LeaveAttachment
+	if err := config.DB.Preload("Leave").First(&a, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+		return
+	}
+	if !canViewLeaveDetails(c.GetUint("userID"), c.GetString("role"), a.Leave) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this leave's documents"})
+		return
+	}
+
+	r, err := storage.Default.Open(a.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment file is missing"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read attachment"})
+		return
+	}
+	defer r.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", strings.ReplaceAll(a.FileName, `"`, "")))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, a.Size, a.ContentType, r, nil)
+}
+
+// DELETE /api/leaves/attachments/:id
+// The uploader or HR can remove a document while the leave is still pending.
+func DeleteLeaveAttachment(c *gin.Context) {
+	var a models.LeaveAttachment
+	if err := config.DB.Preload("Leave").First(&a, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+		return
+	}
+	if a.UploadedBy != c.GetUint("userID") && c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the uploader or HR can remove this document"})
+		return
+	}
+	if a.Leave.Status != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "documents can only be removed while the leave is pending"})
+		return
+	}
+
+	if err := config.DB.Delete(&a).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete attachment"})
+		return
+	}
+	if err := storage.Default.Delete(a.StorageKey); err != nil {
+		log.Printf("deleting attachment file %s: %v", a.StorageKey, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
@@ -0,0 +1,375 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// availability statuses of a calendar cell
+const (
+	availAvailable = "available"
+	availWeekend   = "weekend"
+	availHoliday   = "holiday"
+	availLeave     = "leave"   // approved leave
+	availPending   = "pending" // leave awaiting approval
+)
+
+// the widest range the calendar endpoint returns in one call
+const maxCalendarDays = 93
+
+// CalendarDay is one person's availability on one date.
+type CalendarDay struct {
+	Date      string `json:"date"`
+	Status    string `json:"status"`
+	LeaveID   *uint  `json:"leave_id,omitempty"`
+	LeaveType string `json:"leave_type,omitempty"`
+	Portion   string `json:"portion,omitempty"`
+	Holiday   string `json:"holiday,omitempty"`
+}
+
+// CalendarPerson is one row of the availability matrix.
+type CalendarPerson struct {
+	UserID   uint          `json:"user_id"`
+	Name     string        `json:"name"`
+	Location string        `json:"location"`
+	Days     []CalendarDay `json:"days"`
+}
+
+// CalendarDaySummary counts who is out on a date across the matrix.
+type CalendarDaySummary struct {
+	Date    string `json:"date"`
+	Out     int    `json:"out"`
+	Pending int    `json:"pending"`
+}
+
+type calendarLeave struct {
+	ID        uint
+	UserID    uint
+	Name      string
+	StartDate time.Time
+	EndDate   time.Time
+	Type      string
+	Portion   string
+	Status    string
+}
+
+// calendarUserIDs is who the caller may see on the team calendar: themselves
+// and their team scope (see resolveTeamScope).
+func calendarUserIDs(callerID uint, role string) ([]uint, error) {
+	var ids []uint
+	q := resolveTeamScope(callerID, role).apply(config.DB.Table("employees"), "user_id")
+	if err := q.Distinct("user_id").Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if id == callerID {
+			return ids, nil
+		}
+	}
+	return append(ids, callerID), nil
+}
+
+// loadCalendarLeaves returns the users' leaves with one of the statuses that overlap [from, to].
+func loadCalendarLeaves(userIDs []uint, statuses []string, from, to time.Time) ([]calendarLeave, error) {
+	var items []calendarLeave
+	err := config.DB.Table("leaves l").
+		Select("l.id, l.user_id, u.name, l.start_date, l.end_date, l.type, l.portion, l.status").
+		Joins("JOIN users u ON u.id = l.user_id").
+		Where("l.user_id IN ? AND l.status IN ?", userIDs, statuses).
+		Where("l.start_date <= ? AND l.end_date >= ?", to, from).
+		Order("l.start_date asc").
+		Scan(&items).Error
+	return items, err
+}
+
+// leaveOnDay picks the leave covering d, preferring an approved one over a pending one.
+func leaveOnDay(leaves []calendarLeave, d time.Time) *calendarLeave {
+	var found *calendarLeave
+	for i, l := range leaves {
+		if d.Before(l.StartDate) || d.After(l.EndDate) {
+			continue
+		}
+		if l.Status == "approved" {
+			return &leaves[i]
+		}
+		if found == nil {
+			found = &leaves[i]
+		}
+	}
+	return found
+}
+
+// GET /api/leaves/calendar?from=&to=
+// Per-day, per-person availability for the caller's scope. Defaults to the
+// four weeks starting today; at most 93 days per call.
+func GetLeaveCalendar(c *gin.Context) {
+	userID := c.GetUint("userID")
+	role := c.GetString("role")
+
+	today := time.Now().Truncate(24 * time.Hour)
+	from, to := today, today.AddDate(0, 0, 27)
+	var err error
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+		if c.Query("to") == "" {
+			to = from.AddDate(0, 0, 27)
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to cannot be before from"})
+		return
+	}
+	if to.Sub(from) >= maxCalendarDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("range cannot exceed %d days", maxCalendarDays)})
+		return
+	}
+
+	userIDs, err := calendarUserIDs(userID, role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load team"})
+		return
+	}
+
+	var people []struct {
+		ID       uint
+		Name     string
+		Location string
+	}
+	if err := config.DB.Table("users u").
+		Select("u.id, u.name, COALESCE(e.location, '') AS location").
+		Joins("LEFT JOIN employees e ON e.user_id = u.id").
+		Where("u.id IN ?", userIDs).
+		Order("u.name asc").
+		Scan(&people).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load team"})
+		return
+	}
+
+	leaves, err := loadCalendarLeaves(userIDs, activeLeaveStatuses, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load leaves"})
+		return
+	}
+	leavesByUser := map[uint][]calendarLeave{}
+	for _, l := range leaves {
+		leavesByUser[l.UserID] = append(leavesByUser[l.UserID], l)
+	}
+
+	var dates []time.Time
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d)
+	}
+	summary := make([]CalendarDaySummary, len(dates))
+	for i, d := range dates {
+		summary[i].Date = d.Format("2006-01-02")
+	}
+
+	holidaysAt := map[string]map[string]string{} // location -> date -> name
+	rows := make([]CalendarPerson, 0, len(people))
+	for _, p := range people {
+		key := strings.ToLower(strings.TrimSpace(p.Location))
+		holidays, ok := holidaysAt[key]
+		if !ok {
+			holidays = holidayNamesBetween(p.Location, from, to)
+			holidaysAt[key] = holidays
+		}
+
+		row := CalendarPerson{UserID: p.ID, Name: p.Name, Location: p.Location, Days: make([]CalendarDay, len(dates))}
+		for i, d := range dates {
+			day := CalendarDay{Date: summary[i].Date, Status: availAvailable}
+			if name, ok := holidays[day.Date]; ok {
+				day.Status, day.Holiday = availHoliday, name
+			} else if wd := d.Weekday(); wd == time.Saturday || wd == time.Sunday {
+				day.Status = availWeekend
+			} else if l := leaveOnDay(leavesByUser[p.ID], d); l != nil {
+				id := l.ID
+				day.LeaveID, day.LeaveType, day.Portion = &id, l.Type, l.Portion
+				if l.Status == "approved" {
+					day.Status = availLeave
+					summary[i].Out++
+				} else {
+					day.Status = availPending
+					summary[i].Pending++
+				}
+			}
+			row.Days[i] = day
+		}
+		rows = append(rows, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":    from.Format("2006-01-02"),
+		"to":      to.Format("2006-01-02"),
+		"people":  rows,
+		"summary": summary,
+	})
+}
+
+func newCalendarToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func calendarFeedURL(token string) string {
+	return "/api/calendar/" + token + ".ics"
+}
+
+// GET /api/leaves/calendar/feed
+// Returns the caller's calendar subscription URL, creating the token on first use.
+func GetCalendarFeed(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var t models.CalendarToken
+	err := config.DB.Where("user_id = ?", userID).First(&t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		token, genErr := newCalendarToken()
+		if genErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create calendar token"})
+			return
+		}
+		t = models.CalendarToken{UserID: userID, Token: token}
+		err = config.DB.Create(&t).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load calendar token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"token": t.Token, "url": calendarFeedURL(t.Token)}})
+}
+
+// POST /api/leaves/calendar/feed/rotate
+// Replaces the caller's calendar token; the old subscription URL stops working.
+func RotateCalendarFeed(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	token, err := newCalendarToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create calendar token"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.CalendarToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.CalendarToken{UserID: userID, Token: token}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate calendar token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"token": token, "url": calendarFeedURL(token)}})
+}
+
+// GET /api/calendar/:token.ics (public; the token is the credential)
+// iCalendar feed of approved leaves in the token owner's calendar scope and
+// the holidays of their office, from three months back to a year ahead.
+func LeaveCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	var t models.CalendarToken
+	if err := config.DB.Where("token = ?", token).First(&t).Error; err != nil {
+		c.String(http.StatusNotFound, "calendar not found")
+		return
+	}
+	var user models.User
+	if err := config.DB.First(&user, t.UserID).Error; err != nil {
+		c.String(http.StatusNotFound, "calendar not found")
+		return
+	}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	from, to := today.AddDate(0, -3, 0), today.AddDate(1, 0, 0)
+
+	userIDs, err := calendarUserIDs(user.ID, user.Role)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to load calendar")
+		return
+	}
+	leaves, err := loadCalendarLeaves(userIDs, []string{"approved"}, from, to)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to load calendar")
+		return
+	}
+
+	var holidays []models.Holiday
+	scopeHolidaysToLocation(config.DB.Model(&models.Holiday{}), employeeLocation(user.ID)).
+		Where("date >= ? AND date <= ?", from, to).
+		Order("date asc").
+		Find(&holidays)
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	var b strings.Builder
+	line := func(s string) { b.WriteString(s + "\r\n") }
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//PeopleSoft//Leave Calendar//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + icsEscape("Leave calendar - "+user.Name))
+
+	for _, l := range leaves {
+		summary := fmt.Sprintf("%s - %s leave", l.Name, l.Type)
+		if l.Portion != "" && l.Portion != portionFull {
+			summary += " (" + strings.ReplaceAll(l.Portion, "_", " ") + ")"
+		}
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:leave-%d@peoplesoft", l.ID))
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + l.StartDate.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + l.EndDate.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + icsEscape(summary))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	for _, h := range holidays {
+		line("BEGIN:VEVENT")
+		line(fmt.Sprintf("UID:holiday-%d@peoplesoft", h.ID))
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + h.Date.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + h.Date.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + icsEscape(h.Name))
+		if h.Description != "" {
+			line("DESCRIPTION:" + icsEscape(h.Description))
+		}
+		line("CATEGORIES:HOLIDAY")
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	c.Header("Content-Disposition", `inline; filename="leave-calendar.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(b.String()))
+}
+
+// icsEscape escapes a TEXT value for iCalendar (RFC 5545 §3.3.11).
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,22 @@
+package controllers
+
+import "testing"
+
+func TestICSEscape(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Annual leave", "Annual leave"},
+		{"Asha; Ben, Chen", `Asha\; Ben\, Chen`},
+		{`C:\temp`, `C:\\temp`},
+		{"line one\nline two", `line one\nline two`},
+		{"line one\r\nline two", `line one\nline two`},
+		{`\;`, `\\\;`},
+	}
+	for _, tt := range tests {
+		if got := icsEscape(tt.in); got != tt.want {
+			t.Errorf("icsEscape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
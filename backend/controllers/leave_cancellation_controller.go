@@ -0,0 +1,320 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CancellationRequest struct {
+	From   string `json:"from"` // "YYYY-MM-DD", first day no longer taken; defaults to the start date (full cancellation)
+	Reason string `json:"reason"`
+}
+
+// canAcknowledgeFor reports whether the actor may acknowledge a cancellation
+// of ownerID's leave: their direct manager, someone the manager delegated
+// approvals to, or HR.
+func canAcknowledgeFor(actorID uint, role string, ownerID uint) bool {
+	return actorID != ownerID && resolveTeamScope(actorID, role).handles(ownerID)
+}
+
+// cancellationDays is how many days cancelling the leave from the given date
+// gives back: the working days in [from, end] under the leave's policy,
+// never more than the leave still holds.
+func cancellationDays(db *gorm.DB, leave models.Leave, from time.Time) (float64, error) {
+	charged, err := leaveChargedDays(db, leave)
+	if err != nil {
+		return 0, err
+	}
+	if !from.After(leave.StartDate) {
+		return charged, nil
+	}
+
+	policy, err := resolveLeavePolicy(loadLeaveProfile(leave.UserID), leave.Type)
+	if err != nil {
+		return 0, err
+	}
+	days := roundDays(leaveDaysForUser(leave.UserID, from, leave.EndDate, policy) * portionFraction(leave.Portion, leave.Hours))
+	return min(days, charged), nil
+}
+
+// applyCancellation gives the cancelled days back and cancels or shortens the
+// leave. Runs inside the caller's transaction.
+func applyCancellation(tx *gorm.DB, leave models.Leave, cancel *models.LeaveCancellation, actorID uint) error {
+	alloc, err := lockAllocation(tx, leave.UserID, leave.StartDate.Year(), leave.Type)
+	if err != nil {
+		return err
+	}
+
+	days := min(cancel.Days, alloc.Used)
+	if days > 0 {
+		reason := "approved leave cancelled"
+		if !cancel.Full {
+			reason = "approved leave shortened from " + cancel.CancelFrom.Format("2006-01-02")
+		}
+		leaveID := leave.ID
+		if err := postLeaveTransaction(tx, alloc, models.LeaveTransaction{
+			Kind:      models.LeaveTxnCredit,
+			Days:      days,
+			LeaveID:   &leaveID,
+			Reason:    reason,
+			CreatedBy: &actorID,
+		}); err != nil {
+			return err
+		}
+		if err := rolloverLateRefund(tx, alloc, days); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	cancel.Status, cancel.AcknowledgedBy, cancel.AcknowledgedAt = "acknowledged", &actorID, &now
+	cancel.Days = max(days, 0)
+	if err := tx.Save(cancel).Error; err != nil {
+		return err
+	}
+
+	if cancel.Full {
+		return tx.Model(&leave).Update("status", "cancelled").Error
+	}
+	return tx.Model(&leave).Updates(map[string]interface{}{
+		"end_date": cancel.CancelFrom.AddDate(0, 0, -1),
+		"days":     roundDays(max(leave.Days-cancel.Days, 0)),
+	}).Error
+}
+
+// POST /api/leaves/:id/cancel
+// Body: {"from": "2025-08-14", "reason": "back early"}
+// Cancels an approved leave in full, or from the given date to its end.
+// The employee's request waits for their manager's acknowledgement; HR can
+// cancel directly, including days that are already in the past.
+func CancelApprovedLeave(c *gin.Context) {
+	userID := c.GetUint("userID")
+	role := c.GetString("role")
+
+	var req CancellationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+	}
+
+	var leave models.Leave
+	if err := config.DB.First(&leave, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "leave not found"})
+		return
+	}
+	if leave.UserID != userID && role != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only cancel your own leave"})
+		return
+	}
+	if leave.Status != "approved" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only approved leaves can be cancelled; withdraw pending ones instead"})
+		return
+	}
+
+	from := leave.StartDate
+	if req.From != "" {
+		var err error
+		if from, err = time.Parse("2006-01-02", req.From); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if from.Before(leave.StartDate) || from.After(leave.EndDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must fall within the leave"})
+		return
+	}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	if from.Before(today) && role != "hr" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":         "days already in the past can only be cancelled by HR",
+			"earliest_from": today.Format("2006-01-02"),
+		})
+		return
+	}
+
+	cancel := models.LeaveCancellation{
+		LeaveID:     leave.ID,
+		UserID:      leave.UserID,
+		RequestedBy: userID,
+		CancelFrom:  from,
+		Full:        !from.After(leave.StartDate),
+		Reason:      strings.TrimSpace(req.Reason),
+		Status:      "pending",
+	}
+
+	// the leave's row lock makes the pending check and the insert one step,
+	// so two requests cannot both open a cancellation
+	errPending := errors.New("cancellation already pending")
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockLeaveWithStatus(tx, &leave, "approved"); err != nil {
+			return err
+		}
+		var open int64
+		if err := tx.Model(&models.LeaveCancellation{}).Where("leave_id = ? AND status = ?", leave.ID, "pending").Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return errPending
+		}
+
+		days, err := cancellationDays(tx, leave, from)
+		if err != nil {
+			return err
+		}
+		cancel.Days = days
+		if err := tx.Create(&cancel).Error; err != nil {
+			return err
+		}
+		// HR acting on someone else's leave needs no further acknowledgement
+		if role == "hr" && leave.UserID != userID {
+			return applyCancellation(tx, leave, &cancel, userID)
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "a cancellation for this leave is already awaiting acknowledgement"})
+		return
+	case errors.Is(err, errAlreadyDecided):
+		c.JSON(http.StatusConflict, gin.H{"error": "leave is no longer approved"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel leave"})
+		return
+	}
+
+	status := http.StatusAccepted
+	if cancel.Status == "acknowledged" {
+		status = http.StatusOK
+	}
+	c.JSON(status, gin.H{"data": cancel})
+}
+
+// GET /api/leaves/cancellations?status=
+// The caller's own cancellation requests plus the ones they can acknowledge.
+func ListCancellations(c *gin.Context) {
+	userID := c.GetUint("userID")
+	role := c.GetString("role")
+
+	q := resolveTeamScope(userID, role).applyReports(config.DB.Model(&models.LeaveCancellation{}), "user_id", userID)
+	if s := c.Query("status"); s != "" {
+		q = q.Where("status = ?", strings.ToLower(s))
+	}
+
+	var items []models.LeaveCancellation
+	if err := q.Order("created_at desc").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load cancellations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// PUT /api/leaves/cancellations/:id/acknowledge
+// Body (optional): {"comment": "..."}
+// The manager accepts the cancellation and the days are restored. Once the
+// cancelled dates have started, only HR can accept it.
+func AcknowledgeCancellation(c *gin.Context) {
+	decideCancellation(c, true)
+}
+
+// PUT /api/leaves/cancellations/:id/decline
+// Body (optional): {"comment": "..."}
+// The leave stays approved as it was.
+func DeclineCancellation(c *gin.Context) {
+	decideCancellation(c, false)
+}
+
+func decideCancellation(c *gin.Context, accept bool) {
+	userID := c.GetUint("userID")
+	role := c.GetString("role")
+	comment := bindDecisionComment(c)
+
+	var cancel models.LeaveCancellation
+	if err := config.DB.Where("id = ? AND status = ?", c.Param("id"), "pending").First(&cancel).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cancellation not found or not pending"})
+		return
+	}
+	if !canAcknowledgeFor(userID, role, cancel.UserID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the employee's manager or HR can decide on this cancellation"})
+		return
+	}
+
+	// days already taken are HR's call, as when the cancellation is requested
+	today := time.Now().Truncate(24 * time.Hour)
+	if accept && role != "hr" && cancel.CancelFrom.Before(today) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the cancelled dates have started; only HR can acknowledge cancelling days already in the past"})
+		return
+	}
+
+	errNotApproved := errors.New("leave no longer approved")
+	errNotCovered := errors.New("leave no longer covers the dates")
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockPending(tx, &models.LeaveCancellation{}, cancel.ID); err != nil {
+			return err
+		}
+		var leave models.Leave
+		leave.ID = cancel.LeaveID
+		if err := lockLeaveWithStatus(tx, &leave, "approved"); err != nil {
+			if errors.Is(err, errAlreadyDecided) {
+				return errNotApproved
+			}
+			return err
+		}
+		if cancel.CancelFrom.After(leave.EndDate) {
+			return errNotCovered
+		}
+
+		cancel.Comment = comment
+		if !accept {
+			now := time.Now()
+			cancel.Status, cancel.AcknowledgedBy, cancel.AcknowledgedAt = "declined", &userID, &now
+			return tx.Save(&cancel).Error
+		}
+		// the leave may have been shortened since the request; recount what is left to give back
+		days, err := cancellationDays(tx, leave, cancel.CancelFrom)
+		if err != nil {
+			return err
+		}
+		cancel.Days = days
+		return applyCancellation(tx, leave, &cancel, userID)
+	})
+	switch {
+	case errors.Is(err, errAlreadyDecided):
+		c.JSON(http.StatusConflict, gin.H{"error": "cancellation was already handled"})
+		return
+	case errors.Is(err, errNotApproved):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "leave is no longer approved"})
+		return
+	case errors.Is(err, errNotCovered):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "leave no longer covers the cancelled dates"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update cancellation"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": cancel})
+}
+
+// lockLeaveWithStatus re-reads leave inside tx, locking its row, and fails
+// with errAlreadyDecided unless it still has the given status.
+func lockLeaveWithStatus(tx *gorm.DB, leave *models.Leave, status string) error {
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND status = ?", leave.ID, status).
+		First(leave).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errAlreadyDecided
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"os"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"strconv"
+	"time"
+)
+
+// leave statuses that hold days on the calendar
+var activeLeaveStatuses = []string{"pending", "approved"}
+
+// TeamConflictDay is a day on which approving a leave would take too much of a team out.
+type TeamConflictDay struct {
+	Date     string   `json:"date"`
+	Absent   int      `json:"absent"` // including the leave being approved
+	TeamSize int      `json:"team_size"`
+	Share    float64  `json:"share"`
+	Names    []string `json:"names"` // colleagues already out that day
+}
+
+// teamAbsenceThreshold is the share of a manager's direct reports that may be
+// out on the same day before approvals warn (TEAM_ABSENCE_THRESHOLD, default 0.5).
+func teamAbsenceThreshold() float64 {
+	if v, err := strconv.ParseFloat(os.Getenv("TEAM_ABSENCE_THRESHOLD"), 64); err == nil && v > 0 && v <= 1 {
+		return v
+	}
+	return 0.5
+}
+
+// findOverlappingLeave returns the user's pending or approved leave that clashes
+// with the requested range. Partial-day leaves may share a date as long as
+// they take different halves and together stay within one day.
+func findOverlappingLeave(userID uint, start, end time.Time, portion string, hours float64) (*models.Leave, error) {
+	var existing []models.Leave
+	if err := config.DB.
+		Where("user_id = ? AND status IN ? AND start_date <= ? AND end_date >= ?", userID, activeLeaveStatuses, end, start).
+		Order("start_date asc").
+		Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	if len(existing) == 0 {
+		return nil, nil
+	}
+	if portion == portionFull {
+		return &existing[0], nil
+	}
+
+	taken := portionFraction(portion, hours)
+	for i, l := range existing {
+		if l.Portion == "" || l.Portion == portionFull {
+			return &existing[i], nil
+		}
+		if l.Portion == portion && portion != portionHours {
+			return &existing[i], nil
+		}
+		taken += portionFraction(l.Portion, l.Hours)
+		if taken > 1 {
+			return &existing[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// teamAbsenceConflicts checks the leave against the rest of its owner's team
+// (employees sharing the same manager_id) and returns the weekdays on which
+// the share of the team out, including this leave, would exceed the threshold.
+func teamAbsenceConflicts(leave models.Leave) ([]TeamConflictDay, error) {
+	var owner models.Employee
+	if err := config.DB.Where("user_id = ?", leave.UserID).First(&owner).Error; err != nil || owner.ManagerID == nil {
+		return nil, nil // no team to conflict with
+	}
+
+	var teamSize int64
+	if err := config.DB.Model(&models.Employee{}).Where("manager_id = ?", *owner.ManagerID).Count(&teamSize).Error; err != nil {
+		return nil, err
+	}
+	if teamSize == 0 {
+		return nil, nil
+	}
+
+	var others []struct {
+		UserID    uint
+		Name      string
+		StartDate time.Time
+		EndDate   time.Time
+	}
+	if err := config.DB.Table("leaves l").
+		Select("l.user_id, u.name, l.start_date, l.end_date").
+		Joins("JOIN employees e ON e.user_id = l.user_id").
+		Joins("JOIN users u ON u.id = l.user_id").
+		Where("e.manager_id = ? AND l.user_id <> ? AND l.status = ?", *owner.ManagerID, leave.UserID, "approved").
+		Where("l.start_date <= ? AND l.end_date >= ?", leave.EndDate, leave.StartDate).
+		Scan(&others).Error; err != nil {
+		return nil, err
+	}
+
+	threshold := teamAbsenceThreshold()
+	var conflicts []TeamConflictDay
+	for day := leave.StartDate; !day.After(leave.EndDate); day = day.AddDate(0, 0, 1) {
+		if wd := day.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			continue
+		}
+
+		out := map[uint]string{}
+		for _, o := range others {
+			if !day.Before(o.StartDate) && !day.After(o.EndDate) {
+				out[o.UserID] = o.Name
+			}
+		}
+
+		absent := len(out) + 1
+		share := float64(absent) / float64(teamSize)
+		if share <= threshold {
+			continue
+		}
+
+		names := make([]string, 0, len(out))
+		for _, n := range out {
+			names = append(names, n)
+		}
+		conflicts = append(conflicts, TeamConflictDay{
+			Date:     day.Format("2006-01-02"),
+			Absent:   absent,
+			TeamSize: int(teamSize),
+			Share:    roundDays(share),
+			Names:    names,
+		})
+	}
+	return conflicts, nil
+}
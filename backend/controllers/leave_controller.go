@@ -2,14 +2,18 @@ package controllers
 
 import (
 	"errors"
-	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"os"
 	"peoplesoft/config"
 	"peoplesoft/models"
+	"strconv"
 	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,96 +25,292 @@ type LeaveResponse struct {
 	StartDate      time.Time `json:"start_date"`
 	EndDate        time.Time `json:"end_date"`
 	Type           string    `json:"type"`
+	Portion        string    `json:"portion"`
+	Hours          float64   `json:"hours"`
+	Days           float64   `json:"days"`
 	Reason         string    `json:"reason"`
 	Status         string    `json:"status"`
 	ApprovedBy     *uint     `json:"approved_by"` // Nullable
 	ApprovedByName *string   `json:"approved_by_name"`
+	ApprovalChain  string    `json:"approval_chain"`
+	ApprovalStep   int       `json:"approval_step"`
 	CreatedAt      time.Time `json:"created_at"`
 }
 
 type LeaveRequest struct {
-	StartDate string `json:"start_date"` // "YYYY-MM-DD"
-	EndDate   string `json:"end_date"`   // "YYYY-MM-DD"
-	Type      string `json:"type"`
-	Reason    string `json:"reason"`
+	StartDate string  `json:"start_date" form:"start_date"` // "YYYY-MM-DD"
+	EndDate   string  `json:"end_date" form:"end_date"`     // "YYYY-MM-DD"
+	Type      string  `json:"type" form:"type"`
+	Portion   string  `json:"portion" form:"portion"` // full (default) | first_half | second_half | hours
+	Hours     float64 `json:"hours" form:"hours"`     // required when portion is "hours"
+	Reason    string  `json:"reason" form:"reason"`
+}
+
+// leave portions
+const (
+	portionFull       = "full"
+	portionFirstHalf  = "first_half"
+	portionSecondHalf = "second_half"
+	portionHours      = "hours"
+)
+
+// workdayHours is the length of a working day, used to convert hourly leave
+// into days (LEAVE_HOURS_PER_DAY, default 8).
+func workdayHours() float64 {
+	if v, err := strconv.ParseFloat(os.Getenv("LEAVE_HOURS_PER_DAY"), 64); err == nil && v > 0 {
+		return v
+	}
+	return 8
+}
+
+// portionFraction is the share of a working day a leave portion covers.
+func portionFraction(portion string, hours float64) float64 {
+	switch portion {
+	case portionFirstHalf, portionSecondHalf:
+		return 0.5
+	case portionHours:
+		return hours / workdayHours()
+	default:
+		return 1
+	}
+}
+
+// balances are kept to two decimals (hourly leave yields fractions of a day)
+func roundDays(d float64) float64 {
+	return math.Round(d*100) / 100
+}
+
+// leaveError is a rejected leave operation: the HTTP status and body to answer with.
+type leaveError struct {
+	Status int
+	Body   gin.H
+}
+
+func (e *leaveError) Error() string {
+	msg, _ := e.Body["error"].(string)
+	return msg
+}
+
+func leaveFail(status int, body gin.H) *leaveError {
+	return &leaveError{Status: status, Body: body}
+}
+
+// leaveSubmission is the outcome of a successful leave application.
+type leaveSubmission struct {
+	Leave     models.Leave
+	Days      float64
+	Breakdown []LeaveDay
+	Policy    *models.LeavePolicy
 }
 
 // POST /api/leaves
+// JSON body, or multipart/form-data with the same fields plus one or more
+// "file" parts holding supporting documents.
 func CreateLeave(c *gin.Context) {
-	fmt.Println("test create leaves ")
 	var req LeaveRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	var files []uploadedFile
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+		form, _ := c.MultipartForm()
+		var err error
+		if files, err = readAttachments(form); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
-	fmt.Println("test create leaves 1")
 
 	userID := c.GetUint("userID")
 	if userID == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "missing user id in context"})
 		return
 	}
-	fmt.Println("test create leaves 2")
 
+	sub, lerr := submitLeave(userID, req)
+	if lerr != nil {
+		c.JSON(lerr.Status, lerr.Body)
+		return
+	}
+
+	resp := gin.H{
+		"data":              sub.Leave,
+		"days":              sub.Days,
+		"breakdown":         sub.Breakdown,
+		"document_required": documentRequired(sub.Policy, sub.Days),
+		"attachments":       []models.LeaveAttachment{},
+	}
+	if len(files) > 0 {
+		// the leave stands even if storing a document fails; it can be uploaded again
+		saved, err := saveAttachments(sub.Leave, userID, files)
+		resp["attachments"] = saved
+		if err != nil {
+			log.Printf("saving attachments for leave %d: %v", sub.Leave.ID, err)
+			resp["attachment_error"] = "failed to store attachments; upload them again from the leave"
+		}
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// submitLeave validates a leave application against the user's policy and
+// balance, creates the pending leave and debits its days in the ledger.
+// Shared by the leave API and the chatbot.
+func submitLeave(userID uint, req LeaveRequest) (*leaveSubmission, *leaveError) {
 	start, err1 := time.Parse("2006-01-02", req.StartDate)
 	end, err2 := time.Parse("2006-01-02", req.EndDate)
 	if err1 != nil || err2 != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, expected YYYY-MM-DD"})
-		return
+		return nil, leaveFail(http.StatusBadRequest, gin.H{"error": "invalid date format, expected YYYY-MM-DD"})
 	}
-	fmt.Println("test create leaves 3")
 
 	// Disallow dates before today
 	today := time.Now().Truncate(24 * time.Hour)
 	if start.Before(today) || end.Before(today) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot request leave in the past"})
-		return
+		return nil, leaveFail(http.StatusBadRequest, gin.H{"error": "cannot request leave in the past"})
 	}
 
 	// Ensure start <= end
 	if end.Before(start) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "end date cannot be before start date"})
-		return
+		return nil, leaveFail(http.StatusBadRequest, gin.H{"error": "end date cannot be before start date"})
 	}
 
-	days := workingDaysBetween(start, end)
+	portion := strings.ToLower(strings.TrimSpace(req.Portion))
+	if portion == "" {
+		portion = portionFull
+	}
+	switch portion {
+	case portionFull:
+	case portionFirstHalf, portionSecondHalf, portionHours:
+		if !start.Equal(end) {
+			return nil, leaveFail(http.StatusBadRequest, gin.H{"error": "half-day and hourly leave must start and end on the same date"})
+		}
+	default:
+		return nil, leaveFail(http.StatusBadRequest, gin.H{"error": "portion must be full, first_half, second_half or hours"})
+	}
+	hours := 0.0
+	if portion == portionHours {
+		if req.Hours <= 0 || req.Hours >= workdayHours() {
+			return nil, leaveFail(http.StatusBadRequest, gin.H{
+				"error":         "hours must be more than 0 and less than a working day",
+				"workday_hours": workdayHours(),
+			})
+		}
+		hours = req.Hours
+	}
 
-	if days <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no working days in selected range"})
-		return
+	clash, err := findOverlappingLeave(userID, start, end, portion, hours)
+	if err != nil {
+		return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to check existing leaves"})
+	}
+	if clash != nil {
+		return nil, leaveFail(http.StatusConflict, gin.H{
+			"error":      "you already have a leave covering these dates",
+			"leave_id":   clash.ID,
+			"status":     clash.Status,
+			"start_date": clash.StartDate.Format("2006-01-02"),
+			"end_date":   clash.EndDate.Format("2006-01-02"),
+		})
 	}
 
 	leaveType := strings.ToLower(req.Type)
+
+	profile := loadLeaveProfile(userID)
+	policy, err := resolveLeavePolicy(profile, leaveType)
+	if err != nil {
+		return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to load leave policy"})
+	}
+	if policy == nil {
+		return nil, leaveFail(http.StatusBadRequest, gin.H{"error": "leave type not available", "leave_type": leaveType})
+	}
+
+	if policy.MinNoticeDays > 0 && start.Before(today.AddDate(0, 0, policy.MinNoticeDays)) {
+		return nil, leaveFail(http.StatusBadRequest, gin.H{
+			"error":           "insufficient notice for this leave type",
+			"min_notice_days": policy.MinNoticeDays,
+			"leave_type":      leaveType,
+		})
+	}
+
+	// a full-day request is read together with the leaves it runs into across
+	// weekends and holidays; half-day and hourly leaves are single days,
+	// charged their fraction
+	var breakdown []LeaveDay
+	var touching []models.Leave
+	if portion == portionFull {
+		if breakdown, touching, err = requestDayBreakdown(userID, leaveType, start, end, policy); err != nil {
+			return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to check adjoining leaves"})
+		}
+	} else {
+		breakdown = leaveDayBreakdown(userID, start, end, policy)
+	}
+	fraction := portionFraction(portion, hours)
+	days := 0.0
+	for i := range breakdown {
+		breakdown[i].Charged = roundDays(breakdown[i].Charged * fraction)
+		days += breakdown[i].Charged
+	}
+	days = roundDays(days)
+
+	if days <= 0 {
+		return nil, leaveFail(http.StatusBadRequest, gin.H{"error": "no working days in selected range"})
+	}
+
+	adjoining := 0.0
+	for _, l := range touching {
+		adjoining += l.Days
+	}
+	if policy.MaxConsecutiveDays > 0 && roundDays(days+adjoining) > float64(policy.MaxConsecutiveDays) {
+		return nil, leaveFail(http.StatusBadRequest, gin.H{
+			"error":                "request exceeds the maximum consecutive days for this leave type",
+			"max_consecutive_days": policy.MaxConsecutiveDays,
+			"requested":            days,
+			"adjoining_days":       roundDays(adjoining),
+			"leave_type":           leaveType,
+		})
+	}
+
+	chain, err := approvalChainFor(leaveType, days)
+	if err != nil {
+		return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to load approval rules"})
+	}
+
 	year := start.Year()
 
 	tx := config.DB.Begin()
 
-	// get or create allocation
-	alloc, err := getOrCreateAllocation(userID, year, leaveType)
+	// lock the allocation for the rest of the transaction so a concurrent
+	// request cannot pass the same balance check
+	alloc, err := lockAllocation(tx, userID, year, leaveType)
 	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load allocation"})
-		return
+		return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to load allocation"})
 	}
 
-	remaining := alloc.Total - alloc.Used
+	remaining := roundDays(alloc.Total - alloc.Used)
 	if days > remaining {
 		tx.Rollback()
-		c.JSON(http.StatusBadRequest, gin.H{
+		return nil, leaveFail(http.StatusBadRequest, gin.H{
 			"error":      "insufficient balance",
 			"remaining":  remaining,
 			"requested":  days,
 			"leave_type": leaveType,
 		})
-		return
 	}
 
-	// block the days immediately (on apply)
-	alloc.Used += days
-	if err := tx.Save(alloc).Error; err != nil {
+	// a duplicate submitted concurrently has committed by the time we hold the lock
+	if clash, err := findOverlappingLeave(userID, start, end, portion, hours); err != nil || clash != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update allocation"})
-		return
+		if err != nil {
+			return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to check existing leaves"})
+		}
+		return nil, leaveFail(http.StatusConflict, gin.H{
+			"error":    "you already have a leave covering these dates",
+			"leave_id": clash.ID,
+			"status":   clash.Status,
+		})
 	}
 
 	leave := models.Leave{
@@ -118,18 +318,36 @@ func CreateLeave(c *gin.Context) {
 		StartDate: start,
 		EndDate:   end,
 		Type:      leaveType,
+		Portion:   portion,
+		Hours:     hours,
+		Days:      days,
 		Reason:    req.Reason,
 		Status:    "pending",
+
+		ApprovalChain: chain,
 	}
 
 	if err := tx.Create(&leave).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create leave"})
-		return
+		return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to create leave"})
 	}
 
-	tx.Commit()
-	c.JSON(http.StatusCreated, gin.H{"data": leave})
+	// block the days immediately (on apply)
+	if err := postLeaveTransaction(tx, alloc, models.LeaveTransaction{
+		Kind:      models.LeaveTxnDebit,
+		Days:      -days,
+		LeaveID:   &leave.ID,
+		Reason:    "leave request",
+		CreatedBy: &userID,
+	}); err != nil {
+		tx.Rollback()
+		return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to update allocation"})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to create leave"})
+	}
+	return &leaveSubmission{Leave: leave, Days: days, Breakdown: breakdown, Policy: policy}, nil
 }
 
 // GET /api/leaves/my
@@ -151,10 +369,15 @@ func ListMyLeaves(c *gin.Context) {
 			l.start_date,
 			l.end_date,
 			l.type,
+			l.portion,
+			l.hours,
+			l.days,
 			l.reason,
 			l.status,
 			l.approved_by,
 			au.name AS approved_by_name,
+			l.approval_chain,
+			l.approval_step,
 			l.created_at
 		`).
 		Joins("JOIN users u ON u.id = l.user_id").
@@ -173,8 +396,8 @@ func ListMyLeaves(c *gin.Context) {
 
 // GET /api/leaves/team
 // - HR: all employees’ leaves
-// - Manager: employees where employees.manager_id = manager userID
-// - Employee: colleagues with same manager_id
+// - Manager: their direct reports’ leaves, plus the teams delegated to them
+// - Employee: colleagues with same manager_id (pending/approved only, reason and approver redacted)
 func ListTeamLeaves(c *gin.Context) {
 	role := c.GetString("role")
 	userID := c.GetUint("userID")
@@ -190,26 +413,25 @@ func ListTeamLeaves(c *gin.Context) {
 			l.start_date,
 			l.end_date,
 			l.type,
+			l.portion,
+			l.hours,
+			l.days,
 			l.reason,
 			l.status,
 			l.approved_by,
 			au.name AS approved_by_name,
+			l.approval_chain,
+			l.approval_step,
 			l.created_at
 		`).
 		Joins("JOIN users u ON u.id = l.user_id").
 		Joins("LEFT JOIN users au ON au.id = l.approved_by")
 
-	switch role {
-	case "hr":
-		// HR sees all
-		// no extra filter
-	case "manager":
-		q = q.Joins("JOIN employees e ON e.user_id = l.user_id").
-			Where("e.manager_id = ?", userID)
-	default:
-		// employee – colleagues with same manager (your existing logic)
-		// keep your manager lookup code here and add:
-		// q = q.Joins("JOIN employees e ON e.user_id = l.user_id").Where("e.manager_id = ?", managerID)
+	scope := resolveTeamScope(userID, role)
+	q = scope.apply(q, "l.user_id")
+	if scope.redacted() {
+		// peers only need to know who is (or may be) out
+		q = q.Where("l.status IN ?", activeLeaveStatuses)
 	}
 
 	if err := q.Order("l.created_at DESC").Find(&items).Error; err != nil {
@@ -217,107 +439,191 @@ func ListTeamLeaves(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": items})
+	if scope.redacted() {
+		for i := range items {
+			items[i].Reason = ""
+			items[i].ApprovedBy = nil
+			items[i].ApprovedByName = nil
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": items, "scope": scope.Kind})
 }
 
-// PUT /api/leaves/:id/approve
-// Only manager can approve
+// PUT /api/leaves/:id/approve[?force=true]
+// Body (optional): {"comment": "..."}
+// Approves the step of the leave's approval chain that is currently due; the
+// leave is approved once the last step is. Returns 409 with the clashing days
+// if too much of the employee's team would be out; repeat with force=true to
+// approve anyway.
 func ApproveLeave(c *gin.Context) {
-	role := c.GetString("role")
-	approverID := c.GetUint("userID")
-
-	// Only manager or HR can approve
-	if role != "manager" && role != "hr" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "only managers or HR can approve"})
+	comment := bindDecisionComment(c)
+	resp, lerr := approveLeave(c.Param("id"), c.GetUint("userID"), c.GetString("role"), comment, c.Query("force") == "true")
+	if lerr != nil {
+		c.JSON(lerr.Status, lerr.Body)
 		return
 	}
+	c.JSON(http.StatusOK, resp)
+}
 
-	id := c.Param("id")
-
+// approveLeave approves the current step of a pending leave on behalf of the
+// approver. Shared by ApproveLeave and BulkLeaveDecision.
+func approveLeave(id string, approverID uint, role, comment string, force bool) (gin.H, *leaveError) {
 	// Load the leave first
 	var leave models.Leave
 	if err := config.DB.
 		Where("id = ? AND status = ?", id, "pending").
 		First(&leave).Error; err != nil {
 
-		c.JSON(http.StatusBadRequest, gin.H{"error": "leave not found or not pending"})
-		return
+		return nil, leaveFail(http.StatusBadRequest, gin.H{"error": "leave not found or not pending"})
 	}
 
-	// ❗ Managers cannot approve their own leave
-	if role == "manager" && leave.UserID == approverID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "managers cannot approve their own leave; HR must approve"})
-		return
+	if err := ensureApprovalChain(config.DB, &leave); err != nil {
+		return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to load approval chain"})
 	}
 
-	// HR can approve anything; manager can approve team leaves
-	res := config.DB.Model(&leave).
-		Updates(map[string]interface{}{
-			"status":      "approved",
-			"approved_by": approverID,
+	// ❗ Nobody approves their own leave, and only the approver of the current step can act
+	onBehalfOf, ok := canActOnLeave(approverID, role, leave)
+	if !ok {
+		return nil, leaveFail(http.StatusForbidden, gin.H{
+			"error":    "you cannot approve this leave at its current step",
+			"awaiting": currentApprovalStep(leave),
 		})
+	}
 
-	if res.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to approve leave"})
-		return
+	missing, err := missingRequiredDocument(leave)
+	if err != nil {
+		return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to check supporting documents"})
+	}
+	if missing {
+		return nil, leaveFail(http.StatusBadRequest, gin.H{"error": "a supporting document must be attached before this leave can be approved"})
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "approved"})
+	if !force {
+		conflicts, err := teamAbsenceConflicts(leave)
+		if err != nil {
+			return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to check team availability"})
+		}
+		if len(conflicts) > 0 {
+			return nil, leaveFail(http.StatusConflict, gin.H{
+				"error":     "too many team members would be out on these days; approve with force=true to override",
+				"threshold": teamAbsenceThreshold(),
+				"conflicts": conflicts,
+			})
+		}
+	}
+
+	steps := splitApprovalChain(leave.ApprovalChain)
+	last := mergedApprovalSteps(leave, role)
+	final := last >= len(steps)-1
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.Leave
+		if err := lockPending(tx, &current, leave.ID); err != nil {
+			return err
+		}
+		if current.ApprovalStep != leave.ApprovalStep {
+			return errAlreadyDecided
+		}
+
+		for step := leave.ApprovalStep; step <= last; step++ {
+			if err := tx.Create(&models.LeaveApproval{
+				LeaveID:    leave.ID,
+				Step:       step,
+				StepRole:   approvalStepAt(leave, step),
+				ActorID:    approverID,
+				OnBehalfOf: onBehalfOf,
+				Decision:   "approved",
+				Comment:    comment,
+			}).Error; err != nil {
+				return err
+			}
+			// only the step the actor stood in for is on someone's behalf
+			onBehalfOf = nil
+		}
+
+		if !final {
+			return tx.Model(&leave).Update("approval_step", last+1).Error
+		}
+		return tx.Model(&leave).
+			Updates(map[string]interface{}{
+				"status":      "approved",
+				"approved_by": approverID,
+			}).Error
+	})
+	if errors.Is(err, errAlreadyDecided) {
+		return nil, leaveFail(http.StatusConflict, gin.H{"error": "leave was decided by someone else in the meantime"})
+	}
+	if err != nil {
+		return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to approve leave"})
+	}
+
+	if !final {
+		leave.ApprovalStep = last + 1
+		return gin.H{"message": "step approved", "awaiting": currentApprovalStep(leave)}, nil
+	}
+	return gin.H{"message": "approved"}, nil
 }
 
 // PUT /api/leaves/:id/reject
-// Only manager can reject
+// Body (optional): {"comment": "..."}
+// The approver of the current step can reject, which ends the chain.
 func RejectLeave(c *gin.Context) {
-	role := c.GetString("role")
-	approverID := c.GetUint("userID")
-
-	// Manager or HR can reject
-	if role != "manager" && role != "hr" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "only managers or HR can reject"})
+	comment := bindDecisionComment(c)
+	resp, lerr := rejectLeave(c.Param("id"), c.GetUint("userID"), c.GetString("role"), comment)
+	if lerr != nil {
+		c.JSON(lerr.Status, lerr.Body)
 		return
 	}
+	c.JSON(http.StatusOK, resp)
+}
 
-	id := c.Param("id")
-
+// rejectLeave rejects a pending leave at its current step and gives its days
+// back. Shared by RejectLeave and BulkLeaveDecision.
+func rejectLeave(id string, approverID uint, role, comment string) (gin.H, *leaveError) {
 	tx := config.DB.Begin()
 
 	var leave models.Leave
-	if err := tx.
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
 		Where("id = ? AND status = ?", id, "pending").
 		First(&leave).Error; err != nil {
 
 		tx.Rollback()
-		c.JSON(http.StatusBadRequest, gin.H{"error": "leave not found or not pending"})
-		return
+		return nil, leaveFail(http.StatusBadRequest, gin.H{"error": "leave not found or not pending"})
 	}
 
-	// ❗ Managers cannot reject their own leave either
-	if role == "manager" && leave.UserID == approverID {
+	if err := ensureApprovalChain(tx, &leave); err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusForbidden, gin.H{"error": "managers cannot reject their own leave; HR must handle it"})
-		return
+		return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to load approval chain"})
 	}
 
-	// 🔁 Restore allocation (workingDaysBetween + getOrCreateAllocation)
-	days := workingDaysBetween(leave.StartDate, leave.EndDate)
-	year := leave.StartDate.Year()
-
-	alloc, err := getOrCreateAllocation(leave.UserID, year, leave.Type)
-	if err != nil {
+	// ❗ Nobody rejects their own leave, and only the approver of the current step can act
+	onBehalfOf, ok := canActOnLeave(approverID, role, leave)
+	if !ok {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load allocation"})
-		return
+		return nil, leaveFail(http.StatusForbidden, gin.H{
+			"error":    "you cannot reject this leave at its current step",
+			"awaiting": currentApprovalStep(leave),
+		})
 	}
 
-	alloc.Used -= days
-	if alloc.Used < 0 {
-		alloc.Used = 0
+	if err := tx.Create(&models.LeaveApproval{
+		LeaveID:    leave.ID,
+		Step:       leave.ApprovalStep,
+		StepRole:   currentApprovalStep(leave),
+		ActorID:    approverID,
+		OnBehalfOf: onBehalfOf,
+		Decision:   "rejected",
+		Comment:    comment,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to record decision"})
 	}
 
-	if err := tx.Save(alloc).Error; err != nil {
+	// 🔁 Restore allocation (credit back what the leave debited)
+	if err := creditBackLeave(tx, leave, "leave rejected", approverID); err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update allocation"})
-		return
+		return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to update allocation"})
 	}
 
 	if err := tx.Model(&leave).Updates(map[string]interface{}{
@@ -326,24 +632,27 @@ func RejectLeave(c *gin.Context) {
 	}).Error; err != nil {
 
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update leave"})
-		return
+		return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to update leave"})
 	}
 
-	tx.Commit()
-	c.JSON(http.StatusOK, gin.H{"message": "rejected"})
+	if err := tx.Commit().Error; err != nil {
+		return nil, leaveFail(http.StatusInternalServerError, gin.H{"error": "failed to update leave"})
+	}
+	return gin.H{"message": "rejected"}, nil
 }
 
-// business days (Mon–Fri) inclusive
-func workingDaysBetween(start, end time.Time) int {
+// business days (Mon–Fri) inclusive,
+// excluding the given holiday dates ("YYYY-MM-DD")
+func workingDaysBetween(start, end time.Time, holidays map[string]bool) float64 {
 	if end.Before(start) {
 		return 0
 	}
-	d := 0
+	d := 0.0
 	cur := start
 	for !cur.After(end) {
 		wd := cur.Weekday()
-		if wd != time.Saturday && wd != time.Sunday {
+		weekend := wd == time.Saturday || wd == time.Sunday
+		if !weekend && !holidays[cur.Format("2006-01-02")] {
 			d++
 		}
 		cur = cur.AddDate(0, 0, 1)
@@ -351,51 +660,154 @@ func workingDaysBetween(start, end time.Time) int {
 	return d
 }
 
-// default allocations per type per year
-func defaultAllocationForType(t string) int {
-	switch strings.ToLower(t) {
-	case "sick":
-		return 15
-	case "casual":
-		return 5
-	case "vacation":
-		return 10
+// team visibility scopes
+const (
+	scopeAll   = "all"   // HR: everyone
+	scopeTeam  = "team"  // managers: their reports, including delegated teams
+	scopePeers = "peers" // employees: colleagues with the same manager
+)
+
+// teamScope is whose records a caller may list alongside their own.
+type teamScope struct {
+	Kind    string
+	userIDs *gorm.DB // subquery of visible user ids; nil for scopeAll
+	reports *gorm.DB // subquery of the user ids whose requests the caller handles; nil for scopeAll
+}
+
+// resolveTeamScope applies the HR / manager / peer visibility rules for the
+// caller. Whoever holds an active delegation also handles the delegator's
+// reports, whatever their own role.
+func resolveTeamScope(callerID uint, role string) teamScope {
+	switch role {
+	case "hr":
+		return teamScope{Kind: scopeAll}
+	case "manager":
+		reports := reportsOfApprover(callerID)
+		return teamScope{Kind: scopeTeam, userIDs: reports, reports: reports}
 	default:
-		return 0
+		// employees.manager_id is NULL for people without a manager, who
+		// therefore have no peers
+		return teamScope{Kind: scopePeers, reports: reportsOfApprover(callerID), userIDs: config.DB.Table("employees e").
+			Select("e.user_id").
+			Joins("JOIN employees me ON me.manager_id = e.manager_id").
+			Where("me.user_id = ? AND e.user_id <> ?", callerID, callerID)}
+	}
+}
+
+// apply restricts q to the scope; column holds the user id of each row.
+func (s teamScope) apply(q *gorm.DB, column string) *gorm.DB {
+	if s.userIDs == nil {
+		return q
+	}
+	return q.Where(column+" IN (?)", s.userIDs)
+}
+
+// applyReports restricts q to the caller's own rows and those of the people
+// whose requests they handle, for records peers must not see (balances,
+// cancellations, comp-off, documents).
+func (s teamScope) applyReports(q *gorm.DB, column string, callerID uint) *gorm.DB {
+	if s.reports == nil {
+		return q
+	}
+	return q.Where(column+" = ? OR "+column+" IN (?)", callerID, s.reports)
+}
+
+// handles reports whether the caller handles userID's requests and so may see
+// their private records.
+func (s teamScope) handles(userID uint) bool {
+	if s.reports == nil {
+		return true
 	}
+	var count int64
+	config.DB.Table("(?) AS r", s.reports).Where("r.user_id = ?", userID).Count(&count)
+	return count > 0
+}
+
+// redacted reports whether details private to the requester and their
+// approvers (reason, approver) are hidden in this scope.
+func (s teamScope) redacted() bool {
+	return s.Kind == scopePeers
 }
 
 func getOrCreateAllocation(userID uint, year int, leaveType string) (*models.LeaveAllocation, error) {
-	var alloc models.LeaveAllocation
-	err := config.DB.
-		Where("user_id = ? AND year = ? AND type = ?", userID, year, leaveType).
-		First(&alloc).Error
+	return findOrCreateAllocation(config.DB, userID, year, leaveType, false)
+}
 
+// lockAllocation is getOrCreateAllocation inside tx, holding the row lock
+// (SELECT ... FOR UPDATE) until tx ends, so concurrent requests check and
+// update the balance one after another.
+func lockAllocation(tx *gorm.DB, userID uint, year int, leaveType string) (*models.LeaveAllocation, error) {
+	return findOrCreateAllocation(tx, userID, year, leaveType, true)
+}
+
+// errAlreadyDecided means a pending record was decided by a concurrent
+// request after the caller loaded it.
+var errAlreadyDecided = errors.New("already decided")
+
+// lockPending re-reads the record with the given id inside tx, locking its row,
+// and fails with errAlreadyDecided if it is no longer pending.
+func lockPending(tx *gorm.DB, dest interface{}, id uint) error {
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND status = ?", id, "pending").
+		First(dest).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		total := defaultAllocationForType(leaveType)
-		alloc = models.LeaveAllocation{
-			UserID: userID,
-			Year:   year,
-			Type:   leaveType,
-			Total:  total,
-			Used:   0,
+		return errAlreadyDecided
+	}
+	return err
+}
+
+func findOrCreateAllocation(db *gorm.DB, userID uint, year int, leaveType string, lock bool) (*models.LeaveAllocation, error) {
+	load := func(alloc *models.LeaveAllocation) error {
+		q := db
+		if lock {
+			q = q.Clauses(clause.Locking{Strength: "UPDATE"})
 		}
-		if err := config.DB.Create(&alloc).Error; err != nil {
+		return q.Where("user_id = ? AND year = ? AND type = ?", userID, year, leaveType).First(alloc).Error
+	}
+
+	var alloc models.LeaveAllocation
+	err := load(&alloc)
+	if err == nil {
+		return &alloc, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// the entitlement is credited by the accrual engine, pro-rated from the joining date;
+	// a concurrent request may have created the row first, in which case we just load it
+	alloc = models.LeaveAllocation{
+		UserID: userID,
+		Year:   year,
+		Type:   leaveType,
+		Total:  0,
+		Used:   0,
+	}
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&alloc)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 1 {
+		if err := accrueAllocation(db, &alloc, time.Now()); err != nil {
 			return nil, err
 		}
-		return &alloc, nil
 	}
-	if err != nil {
+
+	alloc = models.LeaveAllocation{}
+	if err := load(&alloc); err != nil {
 		return nil, err
 	}
 	return &alloc, nil
 }
 
 type LeaveBalanceResponse struct {
-	Type      string `json:"type"`
-	Total     int    `json:"total"`
-	Used      int    `json:"used"`
-	Remaining int    `json:"remaining"`
+	Type           string     `json:"type"`
+	Total          float64    `json:"total"`
+	Used           float64    `json:"used"`
+	Remaining      float64    `json:"remaining"`
+	CarriedForward float64    `json:"carried_forward"`
+	ExpiringDays   float64    `json:"expiring_days"` // carried days still unused that will expire
+	ExpiringOn     *time.Time `json:"expiring_on"`
 }
 
 func GetMyLeaveBalance(c *gin.Context) {
@@ -415,36 +827,67 @@ func GetMyLeaveBalance(c *gin.Context) {
 		return
 	}
 
-	// lazily ensure default allocations exist even if empty
-	types := []string{"sick", "casual", "vacation"}
-	existing := map[string]*models.LeaveAllocation{}
-	for i := range allocs {
-		existing[allocs[i].Type] = &allocs[i]
+	eligible, err := eligibleLeaveTypes(loadLeaveProfile(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load leave policies"})
+		return
 	}
 
+	var carries []models.LeaveCarryForward
+	if err := config.DB.
+		Where("user_id = ? AND to_year = ?", userID, year).
+		Find(&carries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load carry-forward"})
+		return
+	}
+	carried := map[string]models.LeaveCarryForward{}
+	for _, cf := range carries {
+		carried[cf.Type] = cf
+	}
+
+	// every type the policy grants (allocations are created lazily, with
+	// accruals posted to date), plus any allocation left over from an older policy
 	var result []LeaveBalanceResponse
-	for _, t := range types {
-		if a, ok := existing[t]; ok {
-			result = append(result, LeaveBalanceResponse{
-				Type:      t,
-				Total:     a.Total,
-				Used:      a.Used,
-				Remaining: a.Total - a.Used,
-			})
-		} else {
-			total := defaultAllocationForType(t)
-			result = append(result, LeaveBalanceResponse{
-				Type:      t,
-				Total:     total,
-				Used:      0,
-				Remaining: total,
-			})
+	seen := map[string]bool{}
+	for _, et := range eligible {
+		seen[et.Code] = true
+		a, err := getOrCreateAllocation(userID, year, et.Code)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load allocations"})
+			return
+		}
+		result = append(result, leaveBalanceFor(*a, carried))
+	}
+	for _, a := range allocs {
+		if seen[a.Type] {
+			continue
 		}
+		result = append(result, leaveBalanceFor(a, carried))
 	}
 
 	c.JSON(http.StatusOK, gin.H{"data": result})
 }
 
+func leaveBalanceFor(a models.LeaveAllocation, carried map[string]models.LeaveCarryForward) LeaveBalanceResponse {
+	b := LeaveBalanceResponse{
+		Type:      a.Type,
+		Total:     a.Total,
+		Used:      a.Used,
+		Remaining: roundDays(a.Total - a.Used),
+	}
+	if cf, ok := carried[a.Type]; ok {
+		b.CarriedForward = cf.Carried
+		// carried days are used first, so whatever usage has not covered is at risk
+		if cf.ExpiresOn != nil && cf.ExpiredAt == nil {
+			b.ExpiringDays = roundDays(max(cf.Carried-a.Used, 0))
+			if b.ExpiringDays > 0 {
+				b.ExpiringOn = cf.ExpiresOn
+			}
+		}
+	}
+	return b
+}
+
 func WithdrawLeave(c *gin.Context) {
 	userID := c.GetUint("userID")
 	if userID == 0 {
@@ -457,7 +900,7 @@ func WithdrawLeave(c *gin.Context) {
 	tx := config.DB.Begin()
 
 	var leave models.Leave
-	if err := tx.
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
 		Where("id = ? AND user_id = ?", id, userID).
 		First(&leave).Error; err != nil {
 
@@ -474,22 +917,7 @@ func WithdrawLeave(c *gin.Context) {
 	}
 
 	// restore allocation
-	days := workingDaysBetween(leave.StartDate, leave.EndDate)
-	year := leave.StartDate.Year()
-
-	alloc, err := getOrCreateAllocation(leave.UserID, year, leave.Type)
-	if err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load allocation"})
-		return
-	}
-
-	alloc.Used -= days
-	if alloc.Used < 0 {
-		alloc.Used = 0
-	}
-
-	if err := tx.Save(alloc).Error; err != nil {
+	if err := creditBackLeave(tx, leave, "leave withdrawn", userID); err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update allocation"})
 		return
@@ -0,0 +1,27 @@
+package controllers
+
+import "testing"
+
+func TestPortionFraction(t *testing.T) {
+	tests := []struct {
+		portion string
+		hours   float64
+		perDay  string // LEAVE_HOURS_PER_DAY
+		want    float64
+	}{
+		{portionFull, 0, "", 1},
+		{"", 0, "", 1},
+		{portionFirstHalf, 0, "", 0.5},
+		{portionSecondHalf, 0, "", 0.5},
+		{portionHours, 2, "", 0.25},
+		{portionHours, 6, "", 0.75},
+		{portionHours, 3, "6", 0.5},
+		{portionHours, 4, "not a number", 0.5},
+	}
+	for _, tt := range tests {
+		t.Setenv("LEAVE_HOURS_PER_DAY", tt.perDay)
+		if got := portionFraction(tt.portion, tt.hours); got != tt.want {
+			t.Errorf("portionFraction(%q, %v) with %q hours a day = %v, want %v", tt.portion, tt.hours, tt.perDay, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EncashmentRequest struct {
+	Type   string  `json:"type"`
+	Days   float64 `json:"days"` // whole or half days
+	Reason string  `json:"reason"`
+}
+
+// encashedDays is what the user has already asked to be paid out for the
+// year and type, split into requests still pending and approved ones (which
+// have left the allocation already).
+func encashedDays(db *gorm.DB, userID uint, year int, leaveType string) (pending, approved float64, err error) {
+	var row struct {
+		Pending  float64
+		Approved float64
+	}
+	err = db.Model(&models.LeaveEncashment{}).
+		Select(`COALESCE(SUM(CASE WHEN status = 'pending' THEN days END), 0) AS pending,
+			COALESCE(SUM(CASE WHEN status = 'approved' THEN days END), 0) AS approved`).
+		Where("user_id = ? AND year = ? AND type = ?", userID, year, leaveType).
+		Scan(&row).Error
+	return row.Pending, row.Approved, err
+}
+
+// POST /api/leaves/encashments
+// Body: {"type": "vacation", "days": 5, "reason": "..."}
+// Encashment is against the current year's allocation, before the year-end
+// rollover carries or lapses what is left of it.
+func CreateEncashment(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req EncashmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	leaveType := strings.ToLower(strings.TrimSpace(req.Type))
+	if leaveType == "" || req.Days <= 0 || math.Mod(req.Days*2, 1) != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type and a positive number of whole or half days are required"})
+		return
+	}
+
+	policy, err := resolveLeavePolicy(loadLeaveProfile(userID), leaveType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load leave policy"})
+		return
+	}
+	if policy == nil || policy.MaxEncashableDays == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "this leave type cannot be encashed", "leave_type": leaveType})
+		return
+	}
+
+	year := time.Now().Year()
+	alloc, err := getOrCreateAllocation(userID, year, leaveType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load allocation"})
+		return
+	}
+	pending, approved, err := encashedDays(config.DB, userID, year, leaveType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load encashments"})
+		return
+	}
+
+	allowed := roundDays(float64(policy.MaxEncashableDays) - pending - approved)
+	if req.Days > allowed {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":               "request exceeds the encashable days for this leave type",
+			"max_encashable_days": policy.MaxEncashableDays,
+			"already_requested":   roundDays(pending + approved),
+			"requested":           req.Days,
+		})
+		return
+	}
+	remaining := roundDays(alloc.Total - alloc.Used - pending)
+	if req.Days > remaining {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":     "insufficient balance",
+			"remaining": max(remaining, 0),
+			"requested": req.Days,
+		})
+		return
+	}
+
+	item := models.LeaveEncashment{
+		UserID: userID,
+		Year:   year,
+		Type:   leaveType,
+		Days:   req.Days,
+		Reason: strings.TrimSpace(req.Reason),
+		Status: "pending",
+	}
+	if err := config.DB.Create(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create encashment request"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": item})
+}
+
+// GET /api/leaves/encashments?status=&year=&user_id=
+// Employees see their own requests; HR sees everyone's.
+func ListEncashments(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	q := config.DB.Model(&models.LeaveEncashment{})
+	if c.GetString("role") != "hr" {
+		q = q.Where("user_id = ?", userID)
+	} else if v := c.Query("user_id"); v != "" {
+		q = q.Where("user_id = ?", v)
+	}
+	if s := c.Query("status"); s != "" {
+		q = q.Where("status = ?", strings.ToLower(s))
+	}
+	if y := c.Query("year"); y != "" {
+		q = q.Where("year = ?", y)
+	}
+
+	var items []models.LeaveEncashment
+	if err := q.Order("created_at desc").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load encashments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// PUT /api/leaves/encashments/:id/approve (HR only)
+// Body (optional): {"comment": "..."}
+// Deducts the days from the allocation.
+func ApproveEncashment(c *gin.Context) {
+	decideEncashment(c, true)
+}
+
+// PUT /api/leaves/encashments/:id/reject (HR only)
+// Body (optional): {"comment": "..."}
+func RejectEncashment(c *gin.Context) {
+	decideEncashment(c, false)
+}
+
+func decideEncashment(c *gin.Context, approve bool) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can decide on encashments"})
+		return
+	}
+	hrID := c.GetUint("userID")
+	comment := bindDecisionComment(c)
+
+	var item models.LeaveEncashment
+	if err := config.DB.Where("id = ? AND status = ?", c.Param("id"), "pending").First(&item).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "encashment not found or not pending"})
+		return
+	}
+	if item.UserID == hrID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you cannot decide your own encashment"})
+		return
+	}
+
+	now := time.Now()
+	item.DecidedBy, item.DecidedAt, item.Comment = &hrID, &now, comment
+	if !approve {
+		item.Status = "rejected"
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := lockPending(tx, &models.LeaveEncashment{}, item.ID); err != nil {
+				return err
+			}
+			return tx.Save(&item).Error
+		})
+		if errors.Is(err, errAlreadyDecided) {
+			c.JSON(http.StatusConflict, gin.H{"error": "encashment was decided in the meantime"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update encashment"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": item})
+		return
+	}
+
+	if item.Year != now.Year() {
+		c.JSON(http.StatusConflict, gin.H{"error": "the allocation year has been rolled over; reject and request again"})
+		return
+	}
+
+	errShort := errors.New("insufficient balance")
+	var alloc *models.LeaveAllocation
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockPending(tx, &models.LeaveEncashment{}, item.ID); err != nil {
+			return err
+		}
+		var err error
+		if alloc, err = lockAllocation(tx, item.UserID, item.Year, item.Type); err != nil {
+			return err
+		}
+		// leave taken since the request may have used the days
+		if item.Days > roundDays(alloc.Total-alloc.Used) {
+			return errShort
+		}
+
+		item.Status = "approved"
+		if err := tx.Save(&item).Error; err != nil {
+			return err
+		}
+		return postLeaveTransaction(tx, alloc, models.LeaveTransaction{
+			Kind:      models.LeaveTxnEncashment,
+			Days:      -item.Days,
+			Reason:    fmt.Sprintf("encashment #%d", item.ID),
+			CreatedBy: &hrID,
+		})
+	})
+	switch {
+	case errors.Is(err, errAlreadyDecided):
+		c.JSON(http.StatusConflict, gin.H{"error": "encashment was decided in the meantime"})
+		return
+	case errors.Is(err, errShort):
+		c.JSON(http.StatusConflict, gin.H{"error": "the employee no longer has enough unused days", "remaining": roundDays(alloc.Total - alloc.Used)})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to approve encashment"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": item, "balance": leaveBalanceFor(*alloc, nil)})
+}
+
+// EncashmentExportRow is a line of the payroll export.
+type EncashmentExportRow struct {
+	ID         uint       `json:"id"`
+	UserID     uint       `json:"user_id"`
+	EmployeeID *uint      `json:"employee_id"`
+	Name       string     `json:"name"`
+	Email      string     `json:"email"`
+	Year       int        `json:"year"`
+	Type       string     `json:"type"`
+	Days       float64    `json:"days"`
+	ApprovedAt *time.Time `json:"approved_at"`
+	ExportedAt *time.Time `json:"exported_at"`
+}
+
+// GET /api/leaves/encashments/export?year=&pending_only=true&format=csv|xlsx|json (HR only)
+// Approved encashments for payroll. pending_only limits it to ones not
+// exported before. Nothing is marked; use the POST to hand rows over.
+func ExportEncashments(c *gin.Context) {
+	exportEncashments(c, false)
+}
+
+// POST /api/leaves/encashments/export?year=&pending_only=true&format=csv|xlsx|json (HR only)
+// Same as the GET, and marks every row returned as exported.
+func ExportEncashmentsToPayroll(c *gin.Context) {
+	exportEncashments(c, true)
+}
+
+func exportEncashments(c *gin.Context, mark bool) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can export encashments"})
+		return
+	}
+	year, err := strconv.Atoi(c.DefaultQuery("year", strconv.Itoa(time.Now().Year())))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+		return
+	}
+	// payroll wants a file, so CSV is the default here
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if !validReportFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv or xlsx"})
+		return
+	}
+
+	rows := []EncashmentExportRow{}
+	table := reportTable{
+		Name:   fmt.Sprintf("leave-encashments-%d", year),
+		Header: []string{"Encashment ID", "User ID", "Employee ID", "Name", "Email", "Year", "Type", "Days", "Approved on"},
+	}
+	var file []byte
+	var contentType string
+
+	// the file is built before any row is marked, and the rows stay locked
+	// until the marks are written, so a failed export marks nothing
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		q := tx.Table("leave_encashments x").
+			Select(`x.id, x.user_id, e.id AS employee_id, u.name, u.email, x.year, x.type, x.days,
+				x.decided_at AS approved_at, x.exported_at`).
+			Joins("JOIN users u ON u.id = x.user_id").
+			Joins("LEFT JOIN employees e ON e.user_id = x.user_id").
+			Where("x.status = ? AND x.year = ?", "approved", year)
+		if c.Query("pending_only") == "true" {
+			q = q.Where("x.exported_at IS NULL")
+		}
+		if mark {
+			q = q.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "x"}})
+		}
+		if err := q.Order("u.name asc, x.id asc").Scan(&rows).Error; err != nil {
+			return err
+		}
+
+		for _, r := range rows {
+			var employeeID any
+			if r.EmployeeID != nil {
+				employeeID = *r.EmployeeID
+			}
+			table.Rows = append(table.Rows, []any{r.ID, r.UserID, employeeID, r.Name, r.Email, r.Year, r.Type, r.Days, r.ApprovedAt})
+		}
+		if format != "json" {
+			var err error
+			if file, contentType, err = buildReportFile(format, table); err != nil {
+				return err
+			}
+		}
+
+		if !mark || len(rows) == 0 {
+			return nil
+		}
+		ids := make([]uint, len(rows))
+		for i, r := range rows {
+			ids[i] = r.ID
+		}
+		return tx.Model(&models.LeaveEncashment{}).
+			Where("id IN ? AND exported_at IS NULL", ids).
+			Update("exported_at", time.Now()).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export encashments"})
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{"data": rows, "marked": mark})
+		return
+	}
+	sendReportFile(c, format, contentType, file, table)
+}
@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// reminder kinds
+const (
+	reminderKindReminder   = "reminder"
+	reminderKindEscalation = "escalation"
+)
+
+// envHours reads a duration in hours from the environment.
+func envHours(name string, def int) time.Duration {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v >= 0 {
+		return time.Duration(v) * time.Hour
+	}
+	return time.Duration(def) * time.Hour
+}
+
+// leaveReminderAfter is how long a step may wait before the approver is
+// reminded (LEAVE_REMINDER_HOURS, default 48).
+func leaveReminderAfter() time.Duration { return envHours("LEAVE_REMINDER_HOURS", 48) }
+
+// leaveEscalationAfter is how long a step may wait before it is escalated
+// (LEAVE_ESCALATION_HOURS, default 96).
+func leaveEscalationAfter() time.Duration { return envHours("LEAVE_ESCALATION_HOURS", 96) }
+
+// leaveAutoApproveMaxDays is the longest leave the scheduler approves by
+// itself once its start date is about to pass (LEAVE_AUTO_APPROVE_MAX_DAYS,
+// default 0 = never).
+func leaveAutoApproveMaxDays() float64 {
+	if v, err := strconv.ParseFloat(os.Getenv("LEAVE_AUTO_APPROVE_MAX_DAYS"), 64); err == nil && v > 0 {
+		return v
+	}
+	return 0
+}
+
+func hrUserIDs(except uint) []uint {
+	var ids []uint
+	config.DB.Model(&models.User{}).Where("role = ? AND id <> ?", "hr", except).Pluck("id", &ids)
+	return ids
+}
+
+// stepStartedAt is when the leave started waiting on its current step: the
+// last decision on it, or when it was applied for.
+func stepStartedAt(leave models.Leave) time.Time {
+	var last *time.Time
+	config.DB.Model(&models.LeaveApproval{}).Select("MAX(created_at)").Where("leave_id = ?", leave.ID).Scan(&last)
+	if last != nil && !last.IsZero() {
+		return *last
+	}
+	return leave.CreatedAt
+}
+
+// stepApprovers are the users who can decide the leave's current step in the
+// normal course: the manager and their active delegates, or HR.
+func stepApprovers(leave models.Leave) []uint {
+	if currentApprovalStep(leave) == approverHR {
+		return hrUserIDs(leave.UserID)
+	}
+	mgr := leaveManagerUserID(leave.UserID)
+	ids := []uint{mgr}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	var delegates []uint
+	config.DB.Model(&models.LeaveApprovalDelegation{}).
+		Distinct("delegate_id").
+		Where("delegator_id = ? AND delegate_id <> ? AND start_date <= ? AND end_date >= ?", mgr, mgr, today, today).
+		Pluck("delegate_id", &delegates)
+	return append(ids, delegates...)
+}
+
+// escalationTargets are the approver's own manager or, failing that, HR.
+func escalationTargets(leave models.Leave) []uint {
+	if currentApprovalStep(leave) == approverManager {
+		mgr := leaveManagerUserID(leave.UserID)
+		if up := leaveManagerUserID(mgr); up != 0 && up != leave.UserID {
+			return []uint{up}
+		}
+	}
+	return hrUserIDs(leave.UserID)
+}
+
+// isEscalatedTo reports whether the leave's current step was escalated to the user.
+func isEscalatedTo(leave models.Leave, userID uint) bool {
+	var count int64
+	config.DB.Model(&models.LeaveReminder{}).
+		Where("leave_id = ? AND step = ? AND kind = ? AND recipient_id = ?", leave.ID, leave.ApprovalStep, reminderKindEscalation, userID).
+		Count(&count)
+	return count > 0
+}
+
+// sendLeaveReminder notifies the recipients once per leave step and kind.
+func sendLeaveReminder(leave models.Leave, kind string, recipients []uint, title, message string) error {
+	var sent int64
+	config.DB.Model(&models.LeaveReminder{}).
+		Where("leave_id = ? AND step = ? AND kind = ?", leave.ID, leave.ApprovalStep, kind).
+		Count(&sent)
+	if sent > 0 || len(recipients) == 0 {
+		return nil
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		for _, r := range recipients {
+			if err := tx.Create(&models.LeaveReminder{
+				LeaveID: leave.ID, Step: leave.ApprovalStep, Kind: kind, RecipientID: r, SentAt: now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		leaveID := leave.ID
+		return notify(tx, recipients, "leave_"+kind, title, message, &leaveID)
+	})
+}
+
+// autoApproveLeave approves every remaining step of the leave on behalf of
+// the system and lets the employee know. A leave decided, withdrawn or moved
+// to another step since it was loaded is left alone.
+func autoApproveLeave(leave models.Leave) error {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.Leave
+		if err := lockPending(tx, &current, leave.ID); err != nil {
+			return err
+		}
+		if current.ApprovalStep != leave.ApprovalStep {
+			return errAlreadyDecided
+		}
+
+		// the scheduler (actor 0) signs off every step still open, as an
+		// approver entitled to the whole chain would
+		last := len(splitApprovalChain(leave.ApprovalChain)) - 1
+		for step := leave.ApprovalStep; step <= last; step++ {
+			if err := tx.Create(&models.LeaveApproval{
+				LeaveID:  leave.ID,
+				Step:     step,
+				StepRole: approvalStepAt(leave, step),
+				Decision: "approved",
+				Comment:  "auto-approved: start date reached without a decision",
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.Leave{}).
+			Where("id = ? AND status = ?", leave.ID, "pending").
+			Updates(map[string]interface{}{
+				"status":        "approved",
+				"approved_by":   0,
+				"approval_step": last,
+			}).Error; err != nil {
+			return err
+		}
+		leaveID := leave.ID
+		return notify(tx, []uint{leave.UserID}, "leave_auto_approved", "Leave approved",
+			fmt.Sprintf("Your %s leave from %s was approved automatically.", leave.Type, leave.StartDate.Format("2006-01-02")), &leaveID)
+	})
+	if errors.Is(err, errAlreadyDecided) {
+		return nil
+	}
+	return err
+}
+
+// canAutoApprove: short, starting by tomorrow, documented and not clashing with the team.
+func canAutoApprove(leave models.Leave, now time.Time) bool {
+	maxDays := leaveAutoApproveMaxDays()
+	if maxDays == 0 || leave.Days <= 0 || leave.Days > maxDays {
+		return false
+	}
+	tomorrow := now.Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if leave.StartDate.After(tomorrow) {
+		return false
+	}
+	if missing, err := missingRequiredDocument(leave); err != nil || missing {
+		return false
+	}
+	conflicts, err := teamAbsenceConflicts(leave)
+	return err == nil && len(conflicts) == 0
+}
+
+// ProcessPendingLeaves reminds approvers of pending leave steps older than
+// the reminder SLA, escalates steps older than the escalation SLA, and
+// auto-approves short leaves that are about to start. Called by the scheduler.
+func ProcessPendingLeaves(now time.Time) error {
+	var leaves []models.Leave
+	if err := config.DB.Where("status = ?", "pending").Order("start_date asc").Find(&leaves).Error; err != nil {
+		return err
+	}
+
+	for _, leave := range leaves {
+		if err := ensureApprovalChain(config.DB, &leave); err != nil {
+			return err
+		}
+
+		if canAutoApprove(leave, now) {
+			if err := autoApproveLeave(leave); err != nil {
+				log.Printf("auto-approving leave %d failed: %v", leave.ID, err)
+			}
+			continue
+		}
+
+		var owner models.User
+		config.DB.First(&owner, leave.UserID)
+		what := fmt.Sprintf("%s's %s leave from %s (%g days)", owner.Name, leave.Type, leave.StartDate.Format("2006-01-02"), leave.Days)
+
+		waiting := now.Sub(stepStartedAt(leave))
+		if waiting >= leaveReminderAfter() {
+			if err := sendLeaveReminder(leave, reminderKindReminder, stepApprovers(leave),
+				"Leave awaiting your approval", what+" is waiting for your decision."); err != nil {
+				log.Printf("reminder for leave %d failed: %v", leave.ID, err)
+			}
+		}
+		if waiting >= leaveEscalationAfter() {
+			if err := sendLeaveReminder(leave, reminderKindEscalation, escalationTargets(leave),
+				"Leave approval escalated", what+" has had no decision and was escalated to you."); err != nil {
+				log.Printf("escalation for leave %d failed: %v", leave.ID, err)
+			}
+		}
+	}
+	return nil
+}
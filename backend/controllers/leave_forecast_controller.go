@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BalanceForecast projects one leave type's balance to a future date.
+// Booked leave (pending or approved) already holds its days against the
+// balance, so it is part of Remaining; the Upcoming fields only break it down.
+type BalanceForecast struct {
+	Type              string    `json:"type"`
+	Date              time.Time `json:"date"`
+	Remaining         float64   `json:"remaining"`          // today
+	UpcomingApproved  float64   `json:"upcoming_approved"`  // future approved leave, already deducted
+	UpcomingPending   float64   `json:"upcoming_pending"`   // future pending leave, already deducted
+	Accruals          float64   `json:"accruals"`           // credits scheduled up to the date
+	Expiring          float64   `json:"expiring"`           // carried-forward or comp-off days lapsing by the date
+	PendingEncashment float64   `json:"pending_encashment"` // awaiting HR, deducted if approved
+	Projected         float64   `json:"projected"`
+}
+
+// forecastBalances projects each of the user's current-year balances to date
+// (within the current year): what is left today, plus accrual periods not yet
+// credited that start by the date, minus days that lapse before it and
+// encashments awaiting approval.
+func forecastBalances(userID uint, date time.Time) ([]BalanceForecast, error) {
+	today := time.Now().Truncate(24 * time.Hour)
+	year := today.Year()
+
+	profile := loadLeaveProfile(userID)
+	eligible, err := eligibleLeaveTypes(profile)
+	if err != nil {
+		return nil, err
+	}
+	var allocs []models.LeaveAllocation
+	if err := config.DB.Where("user_id = ? AND year = ?", userID, year).Find(&allocs).Error; err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, a := range allocs {
+		seen[a.Type] = true
+	}
+	for _, et := range eligible {
+		if seen[et.Code] {
+			continue
+		}
+		a, err := getOrCreateAllocation(userID, year, et.Code)
+		if err != nil {
+			return nil, err
+		}
+		allocs = append(allocs, *a)
+	}
+	sort.Slice(allocs, func(i, j int) bool { return allocs[i].Type < allocs[j].Type })
+
+	var upcoming []struct {
+		Type   string
+		Status string
+		Days   float64
+	}
+	if err := config.DB.Model(&models.Leave{}).
+		Select("type, status, COALESCE(SUM(days), 0) AS days").
+		Where("user_id = ? AND start_date > ? AND start_date < ? AND status IN ?",
+			userID, today, time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC), activeLeaveStatuses).
+		Group("type, status").
+		Scan(&upcoming).Error; err != nil {
+		return nil, err
+	}
+
+	var encashments []models.LeaveEncashment
+	if err := config.DB.Where("user_id = ? AND year = ? AND status = ?", userID, year, "pending").
+		Find(&encashments).Error; err != nil {
+		return nil, err
+	}
+
+	var posted []models.LeaveAccrual
+	if err := config.DB.Where("user_id = ? AND year = ?", userID, year).Find(&posted).Error; err != nil {
+		return nil, err
+	}
+	postedOn := map[string]bool{}
+	for _, p := range posted {
+		postedOn[p.Type+"|"+p.PeriodStart.Format("2006-01-02")] = true
+	}
+
+	var carries []models.LeaveCarryForward
+	if err := config.DB.
+		Where("user_id = ? AND to_year = ? AND expires_on IS NOT NULL AND expires_on < ? AND expired_at IS NULL", userID, year, date).
+		Find(&carries).Error; err != nil {
+		return nil, err
+	}
+
+	var credits []models.CompOffRequest
+	if err := config.DB.
+		Where("user_id = ? AND status = ? AND expired_at IS NULL", userID, "approved").
+		Order("expires_on asc, id asc").
+		Find(&credits).Error; err != nil {
+		return nil, err
+	}
+
+	joined := joiningDate(userID)
+
+	result := make([]BalanceForecast, 0, len(allocs))
+	for _, a := range allocs {
+		f := BalanceForecast{Type: a.Type, Date: date, Remaining: roundDays(a.Total - a.Used)}
+		for _, u := range upcoming {
+			if u.Type != a.Type {
+				continue
+			}
+			if u.Status == "approved" {
+				f.UpcomingApproved = roundDays(u.Days)
+			} else {
+				f.UpcomingPending = roundDays(u.Days)
+			}
+		}
+		for _, e := range encashments {
+			if e.Type == a.Type {
+				f.PendingEncashment += e.Days
+			}
+		}
+
+		policy, err := resolveLeavePolicy(profile, a.Type)
+		if err != nil {
+			return nil, err
+		}
+		if policy != nil {
+			for _, p := range buildAccrualSchedule(policy, year, joined) {
+				if !p.PeriodStart.After(date) && !postedOn[a.Type+"|"+p.PeriodStart.Format("2006-01-02")] {
+					f.Accruals += p.Days
+				}
+			}
+		}
+
+		// same rules as ExpireCarriedForward and ExpireCompOffCredits
+		for _, cf := range carries {
+			if cf.Type == a.Type {
+				f.Expiring += max(cf.Carried-a.Used, 0)
+			}
+		}
+		if a.Type == compOffLeaveType {
+			balance := a.Total - a.Used - f.Expiring
+			for i, credit := range credits {
+				if credit.ExpiresOn == nil || !credit.ExpiresOn.Before(date) {
+					continue
+				}
+				newer := 0.0
+				for _, later := range credits[i+1:] {
+					newer += later.Days
+				}
+				expired := min(max(balance-newer, 0), credit.Days)
+				f.Expiring += expired
+				balance -= expired
+			}
+		}
+
+		f.Accruals, f.Expiring, f.PendingEncashment = roundDays(f.Accruals), roundDays(f.Expiring), roundDays(f.PendingEncashment)
+		f.Projected = roundDays(f.Remaining + f.Accruals - f.Expiring - f.PendingEncashment)
+		result = append(result, f)
+	}
+	return result, nil
+}
+
+// GET /api/leaves/balance/forecast?date=YYYY-MM-DD
+// Defaults to the last day of the year. Forecasts stop there: what carries
+// into next year depends on the year-end rollover, so the response gives the
+// last date it can project to.
+func GetLeaveBalanceForecast(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	today := time.Now().Truncate(24 * time.Hour)
+	yearEnd := time.Date(today.Year(), 12, 31, 0, 0, 0, 0, time.UTC)
+	date := yearEnd
+	if v := c.Query("date"); v != "" {
+		var err error
+		if date, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if date.Before(today) || date.Year() != today.Year() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":    "date must be between today and the end of the current year",
+			"max_date": yearEnd.Format("2006-01-02"),
+		})
+		return
+	}
+
+	items, err := forecastBalances(userID, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to forecast balances"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":     items,
+		"max_date": yearEnd.Format("2006-01-02"),
+		"note":     "balances are projected to the end of the current year at most; unused days then carry forward under the policy's cap",
+	})
+}
@@ -0,0 +1,377 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AllocationDrift is an allocation whose cached Total/Used disagree with the ledger.
+type AllocationDrift struct {
+	UserID      uint    `json:"user_id"`
+	Year        int     `json:"year"`
+	Type        string  `json:"type"`
+	CachedTotal float64 `json:"cached_total"`
+	CachedUsed  float64 `json:"cached_used"`
+	LedgerTotal float64 `json:"ledger_total"`
+	LedgerUsed  float64 `json:"ledger_used"`
+	// the allocation has no ledger rows at all, not even an opening balance
+	MissingOpening bool `json:"missing_opening"`
+}
+
+// AllocationDuplicate is a (user_id, year, type) held by more than one
+// allocation row.
+type AllocationDuplicate struct {
+	UserID uint
+	Year   int
+	Type   string
+	Rows   int
+	Total  float64 // largest total of the rows, which the merge keeps
+	Used   float64 // sum of the rows' used days
+}
+
+// postLeaveTransaction appends an entry to the ledger and applies it to the
+// cached totals on the allocation row. UserID, Year and Type are taken from alloc.
+func postLeaveTransaction(db *gorm.DB, alloc *models.LeaveAllocation, t models.LeaveTransaction) error {
+	if err := ensureOpeningBalance(db, alloc); err != nil {
+		return err
+	}
+
+	t.UserID, t.Year, t.Type = alloc.UserID, alloc.Year, alloc.Type
+	if err := db.Create(&t).Error; err != nil {
+		return err
+	}
+
+	if t.AffectsUsage() {
+		alloc.Used -= t.Days
+		return db.Model(alloc).Update("used", gorm.Expr("used - ?", t.Days)).Error
+	}
+	alloc.Total += t.Days
+	return db.Model(alloc).Update("total", gorm.Expr("total + ?", t.Days)).Error
+}
+
+// ensureOpeningBalance gives an allocation that predates the ledger an opening
+// grant and debit matching its current totals, so the ledger stays the
+// source of truth without rewriting history.
+func ensureOpeningBalance(db *gorm.DB, alloc *models.LeaveAllocation) error {
+	if alloc.Total == 0 && alloc.Used == 0 {
+		return nil
+	}
+
+	var count int64
+	if err := db.Model(&models.LeaveTransaction{}).
+		Where("user_id = ? AND year = ? AND type = ?", alloc.UserID, alloc.Year, alloc.Type).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	opening := []models.LeaveTransaction{{
+		UserID: alloc.UserID, Year: alloc.Year, Type: alloc.Type,
+		Kind: models.LeaveTxnGrant, Days: alloc.Total, Reason: "opening balance",
+	}}
+	if alloc.Used != 0 {
+		opening = append(opening, models.LeaveTransaction{
+			UserID: alloc.UserID, Year: alloc.Year, Type: alloc.Type,
+			Kind: models.LeaveTxnDebit, Days: -alloc.Used, Reason: "opening balance",
+		})
+	}
+	return db.Create(&opening).Error
+}
+
+// leaveChargedDays is what a leave currently holds against its allocation:
+// the net of its ledger entries, or for leaves booked before the ledger
+// existed, the day count recomputed from the policy.
+func leaveChargedDays(db *gorm.DB, leave models.Leave) (float64, error) {
+	var row struct {
+		Entries int
+		Net     float64
+	}
+	if err := db.Model(&models.LeaveTransaction{}).
+		Select("COUNT(*) AS entries, COALESCE(SUM(days), 0) AS net").
+		Where("leave_id = ?", leave.ID).
+		Scan(&row).Error; err != nil {
+		return 0, err
+	}
+	if row.Entries > 0 {
+		return -row.Net, nil
+	}
+	if leave.Days > 0 {
+		return leave.Days, nil
+	}
+
+	policy, err := resolveLeavePolicy(loadLeaveProfile(leave.UserID), leave.Type)
+	if err != nil {
+		return 0, err
+	}
+	return leaveDaysForUser(leave.UserID, leave.StartDate, leave.EndDate, policy), nil
+}
+
+// creditBackLeave returns the days a leave holds to its allocation.
+func creditBackLeave(db *gorm.DB, leave models.Leave, reason string, actorID uint) error {
+	days, err := leaveChargedDays(db, leave)
+	if err != nil {
+		return err
+	}
+
+	alloc, err := lockAllocation(db, leave.UserID, leave.StartDate.Year(), leave.Type)
+	if err != nil {
+		return err
+	}
+
+	// never give back more than is in use (pre-ledger leaves could be miscounted)
+	days = min(days, alloc.Used)
+	if days <= 0 {
+		return nil
+	}
+
+	leaveID := leave.ID
+	if err := postLeaveTransaction(db, alloc, models.LeaveTransaction{
+		Kind:      models.LeaveTxnCredit,
+		Days:      days,
+		LeaveID:   &leaveID,
+		Reason:    reason,
+		CreatedBy: &actorID,
+	}); err != nil {
+		return err
+	}
+	return rolloverLateRefund(db, alloc, days)
+}
+
+// POST /api/leaves/adjustments (HR only)
+// Body: {"user_id": 7, "year": 2025, "type": "vacation", "days": -2, "reason": "..."}
+func CreateLeaveAdjustment(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can adjust leave balances"})
+		return
+	}
+	hrID := c.GetUint("userID")
+
+	var in struct {
+		UserID uint    `json:"user_id"`
+		Year   int     `json:"year"`
+		Type   string  `json:"type"`
+		Days   float64 `json:"days"`
+		Reason string  `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if in.Year == 0 {
+		in.Year = time.Now().Year()
+	}
+	leaveType := strings.ToLower(strings.TrimSpace(in.Type))
+	reason := strings.TrimSpace(in.Reason)
+	in.Days = roundDays(in.Days)
+	if in.UserID == 0 || leaveType == "" || in.Days == 0 || reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id, type, non-zero days and reason are required"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, in.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	var alloc *models.LeaveAllocation
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if alloc, err = lockAllocation(tx, in.UserID, in.Year, leaveType); err != nil {
+			return err
+		}
+		return postLeaveTransaction(tx, alloc, models.LeaveTransaction{
+			Kind:      models.LeaveTxnAdjustment,
+			Days:      in.Days,
+			Reason:    reason,
+			CreatedBy: &hrID,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to post adjustment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": leaveBalanceFor(*alloc, nil)})
+}
+
+// GET /api/leaves/ledger?user_id=&year=&type=
+// Employees see their own ledger; managers their direct reports; HR anyone.
+func ListLeaveLedger(c *gin.Context) {
+	callerID := c.GetUint("userID")
+	role := c.GetString("role")
+
+	targetID := callerID
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+			return
+		}
+		targetID = uint(id)
+	}
+	if targetID != callerID && !resolveTeamScope(callerID, role).handles(targetID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this user's ledger"})
+		return
+	}
+
+	year, err := strconv.Atoi(c.DefaultQuery("year", strconv.Itoa(time.Now().Year())))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+		return
+	}
+
+	q := config.DB.Where("user_id = ? AND year = ?", targetID, year)
+	if t := strings.ToLower(c.Query("type")); t != "" {
+		q = q.Where("type = ?", t)
+	}
+
+	var items []models.LeaveTransaction
+	if err := q.Order("created_at asc, id asc").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load ledger"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// MergeDuplicateLeaveAllocations folds allocation rows that repeat a
+// (user_id, year, type) into the oldest one, so the unique index on those
+// columns can be created. Run before AutoMigrate; afterwards
+// cmd/reconcile-leaves can rebuild the merged totals from the ledger.
+func MergeDuplicateLeaveAllocations() error {
+	if !config.DB.Migrator().HasTable(&models.LeaveAllocation{}) {
+		return nil
+	}
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Exec(`
+			UPDATE leave_allocations a
+			SET total = d.total, used = d.used
+			FROM (
+				SELECT MIN(id) AS id, MAX(total) AS total, SUM(used) AS used
+				FROM leave_allocations
+				GROUP BY user_id, year, type
+				HAVING COUNT(*) > 1
+			) d
+			WHERE a.id = d.id`)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		log.Printf("merging %d duplicated leave allocations", res.RowsAffected)
+		return tx.Exec(`
+			DELETE FROM leave_allocations a
+			USING leave_allocations b
+			WHERE a.user_id = b.user_id AND a.year = b.year AND a.type = b.type AND a.id > b.id`).Error
+	})
+}
+
+// DuplicateLeaveAllocations lists the allocation rows that
+// MergeDuplicateLeaveAllocations would fold together, writing nothing.
+func DuplicateLeaveAllocations() ([]AllocationDuplicate, error) {
+	dups := []AllocationDuplicate{}
+	if !config.DB.Migrator().HasTable(&models.LeaveAllocation{}) {
+		return dups, nil
+	}
+	err := config.DB.Model(&models.LeaveAllocation{}).
+		Select("user_id, year, type, COUNT(*) AS rows, MAX(total) AS total, SUM(used) AS used").
+		Group("user_id, year, type").
+		Having("COUNT(*) > 1").
+		Order("user_id, year, type").
+		Scan(&dups).Error
+	return dups, err
+}
+
+// ReconcileLeaveAllocations compares every allocation with the totals derived
+// from the ledger and returns the ones that drifted, writing nothing. With
+// apply set, the allocation rows are rewritten from the ledger (and created if
+// missing), and allocations without ledger rows get their opening balance.
+func ReconcileLeaveAllocations(apply bool) ([]AllocationDrift, error) {
+	var allocs []models.LeaveAllocation
+	if err := config.DB.Find(&allocs).Error; err != nil {
+		return nil, err
+	}
+
+	key := func(userID uint, year int, t string) string { return fmt.Sprintf("%d|%d|%s", userID, year, t) }
+	byKey := map[string]*models.LeaveAllocation{}
+	for i := range allocs {
+		byKey[key(allocs[i].UserID, allocs[i].Year, allocs[i].Type)] = &allocs[i]
+	}
+
+	var sums []struct {
+		UserID uint
+		Year   int
+		Type   string
+		Total  float64
+		Used   float64
+	}
+	if err := config.DB.Model(&models.LeaveTransaction{}).
+		Select(`user_id, year, type,
+			COALESCE(SUM(CASE WHEN kind IN ? THEN 0 ELSE days END), 0) AS total,
+			-COALESCE(SUM(CASE WHEN kind IN ? THEN days ELSE 0 END), 0) AS used`,
+			[]string{models.LeaveTxnDebit, models.LeaveTxnCredit},
+			[]string{models.LeaveTxnDebit, models.LeaveTxnCredit}).
+		Group("user_id, year, type").
+		Scan(&sums).Error; err != nil {
+		return nil, err
+	}
+
+	// an allocation the ledger has never seen drifts by its whole balance;
+	// applying writes its opening rows, after which the two agree
+	inLedger := make(map[string]bool, len(sums))
+	for _, s := range sums {
+		inLedger[key(s.UserID, s.Year, s.Type)] = true
+	}
+	drifts := []AllocationDrift{}
+	for i := range allocs {
+		alloc := &allocs[i]
+		if inLedger[key(alloc.UserID, alloc.Year, alloc.Type)] || (alloc.Total == 0 && alloc.Used == 0) {
+			continue
+		}
+		drifts = append(drifts, AllocationDrift{
+			UserID: alloc.UserID, Year: alloc.Year, Type: alloc.Type,
+			CachedTotal: alloc.Total, CachedUsed: alloc.Used, MissingOpening: true,
+		})
+		if apply {
+			if err := ensureOpeningBalance(config.DB, alloc); err != nil {
+				return drifts, err
+			}
+		}
+	}
+
+	for _, s := range sums {
+		alloc, ok := byKey[key(s.UserID, s.Year, s.Type)]
+		s.Total, s.Used = roundDays(s.Total), roundDays(s.Used)
+		if ok && roundDays(alloc.Total) == s.Total && roundDays(alloc.Used) == s.Used {
+			continue
+		}
+
+		drift := AllocationDrift{UserID: s.UserID, Year: s.Year, Type: s.Type, LedgerTotal: s.Total, LedgerUsed: s.Used}
+		if ok {
+			drift.CachedTotal, drift.CachedUsed = alloc.Total, alloc.Used
+		}
+		drifts = append(drifts, drift)
+
+		if !apply {
+			continue
+		}
+		if !ok {
+			alloc = &models.LeaveAllocation{UserID: s.UserID, Year: s.Year, Type: s.Type}
+		}
+		alloc.Total, alloc.Used = s.Total, s.Used
+		if err := config.DB.Save(alloc).Error; err != nil {
+			return drifts, err
+		}
+	}
+	return drifts, nil
+}
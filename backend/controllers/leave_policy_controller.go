@@ -0,0 +1,424 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// leaveProfile is the subset of a user's record that leave policies are evaluated against.
+type leaveProfile struct {
+	UserID       uint
+	Role         string
+	DepartmentID uint
+	Location     string
+}
+
+type LeavePolicyRequest struct {
+	Role               string `json:"role"`
+	DepartmentID       *uint  `json:"department_id"`
+	Location           string `json:"location"`
+	AnnualEntitlement  int    `json:"annual_entitlement"`
+	MaxConsecutiveDays int    `json:"max_consecutive_days"`
+	MinNoticeDays      int    `json:"min_notice_days"`
+	CountWeekends      bool   `json:"count_weekends"`
+	CountHolidays      bool   `json:"count_holidays"`
+	SandwichRule       bool   `json:"sandwich_rule"`
+	RequiresDocument   bool   `json:"requires_document"`
+	DocumentAfterDays  int    `json:"document_after_days"`
+	AccrualFrequency   string `json:"accrual_frequency"` // annual (default) | quarterly | monthly
+
+	CarryForwardCap          int `json:"carry_forward_cap"`
+	CarryForwardExpiryMonths int `json:"carry_forward_expiry_months"`
+	MaxEncashableDays        int `json:"max_encashable_days"`
+}
+
+// EligibleLeaveType is a leave type together with the policy that applies to the caller.
+type EligibleLeaveType struct {
+	Code   string             `json:"code"`
+	Name   string             `json:"name"`
+	Policy models.LeavePolicy `json:"policy"`
+}
+
+// EnsureDefaultLeaveTypes seeds the original sick/casual/vacation entitlements
+// the first time the leave_types table is empty, and makes sure the comp-off
+// type exists.
+func EnsureDefaultLeaveTypes() error {
+	var count int64
+	if err := config.DB.Model(&models.LeaveType{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ensureCompOffLeaveType()
+	}
+
+	defaults := []struct {
+		Code, Name string
+		Days       int
+	}{
+		{"sick", "Sick Leave", 15},
+		{"casual", "Casual Leave", 5},
+		{"vacation", "Vacation", 10},
+	}
+
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, d := range defaults {
+			lt := models.LeaveType{
+				Code:     d.Code,
+				Name:     d.Name,
+				Active:   true,
+				Policies: []models.LeavePolicy{{AnnualEntitlement: d.Days}},
+			}
+			if err := tx.Create(&lt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return ensureCompOffLeaveType()
+}
+
+// ensureCompOffLeaveType creates the comp-off leave type. It has no
+// entitlement of its own (only approved comp-off requests credit it) and
+// its balance is carried into the next year in full, since each credit
+// expires on its own schedule.
+func ensureCompOffLeaveType() error {
+	var count int64
+	if err := config.DB.Model(&models.LeaveType{}).Where("code = ?", compOffLeaveType).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return config.DB.Create(&models.LeaveType{
+		Code:     compOffLeaveType,
+		Name:     "Compensatory Off",
+		Active:   true,
+		Policies: []models.LeavePolicy{{AnnualEntitlement: 0, CarryForwardCap: 366}},
+	}).Error
+}
+
+// GET /api/leave-types
+// - HR: every leave type with all of its policies
+// - Others: the active types they are eligible for, with the policy that applies
+func ListLeaveTypes(c *gin.Context) {
+	if c.GetString("role") == "hr" {
+		var types []models.LeaveType
+		if err := config.DB.Preload("Policies").Order("code asc").Find(&types).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load leave types"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": types})
+		return
+	}
+
+	eligible, err := eligibleLeaveTypes(loadLeaveProfile(c.GetUint("userID")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load leave types"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": eligible})
+}
+
+// POST /api/leave-types (HR only)
+func CreateLeaveType(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage leave policies"})
+		return
+	}
+
+	var in struct {
+		Code string `json:"code"`
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	code := strings.ToLower(strings.TrimSpace(in.Code))
+	name := strings.TrimSpace(in.Name)
+	if code == "" || name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and name are required"})
+		return
+	}
+
+	lt := models.LeaveType{Code: code, Name: name, Active: true}
+	if err := config.DB.Create(&lt).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "leave type already exists or db error"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": lt})
+}
+
+// PUT /api/leave-types/:id (HR only)
+func UpdateLeaveType(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage leave policies"})
+		return
+	}
+
+	var in struct {
+		Name   *string `json:"name"`
+		Active *bool   `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+
+	updates := map[string]any{}
+	if in.Name != nil {
+		updates["name"] = strings.TrimSpace(*in.Name)
+	}
+	if in.Active != nil {
+		updates["active"] = *in.Active
+	}
+
+	tx := config.DB.Model(&models.LeaveType{}).Where("id = ?", c.Param("id")).Updates(updates)
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "updated"})
+}
+
+// POST /api/leave-types/:id/policies (HR only)
+func CreateLeavePolicy(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage leave policies"})
+		return
+	}
+
+	var lt models.LeaveType
+	if err := config.DB.First(&lt, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "leave type not found"})
+		return
+	}
+
+	var req LeavePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if msg := validateLeavePolicyRequest(req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	policy := leavePolicyFromRequest(req)
+	policy.LeaveTypeID = lt.ID
+	if err := config.DB.Create(&policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create policy"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": policy})
+}
+
+// PUT /api/leave-policies/:id (HR only) — replaces the policy's rules
+func UpdateLeavePolicy(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage leave policies"})
+		return
+	}
+
+	var policy models.LeavePolicy
+	if err := config.DB.First(&policy, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+
+	var req LeavePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if msg := validateLeavePolicyRequest(req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	updated := leavePolicyFromRequest(req)
+	updated.ID = policy.ID
+	updated.LeaveTypeID = policy.LeaveTypeID
+	updated.CreatedAt = policy.CreatedAt
+	if err := config.DB.Save(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": updated})
+}
+
+// DELETE /api/leave-policies/:id (HR only)
+func DeleteLeavePolicy(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage leave policies"})
+		return
+	}
+
+	tx := config.DB.Delete(&models.LeavePolicy{}, c.Param("id"))
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+func validateLeavePolicyRequest(req LeavePolicyRequest) string {
+	if req.AnnualEntitlement < 0 || req.MaxConsecutiveDays < 0 || req.MinNoticeDays < 0 || req.DocumentAfterDays < 0 ||
+		req.CarryForwardCap < 0 || req.CarryForwardExpiryMonths < 0 || req.MaxEncashableDays < 0 {
+		return "policy values cannot be negative"
+	}
+	if req.CarryForwardExpiryMonths > 12 {
+		return "carry_forward_expiry_months cannot exceed 12"
+	}
+	if accrualPeriodsPerYear(req.AccrualFrequency) == 0 {
+		return "accrual_frequency must be annual, quarterly or monthly"
+	}
+	return ""
+}
+
+func leavePolicyFromRequest(req LeavePolicyRequest) models.LeavePolicy {
+	return models.LeavePolicy{
+		Role:               strings.ToLower(strings.TrimSpace(req.Role)),
+		DepartmentID:       req.DepartmentID,
+		Location:           strings.TrimSpace(req.Location),
+		AnnualEntitlement:  req.AnnualEntitlement,
+		MaxConsecutiveDays: req.MaxConsecutiveDays,
+		MinNoticeDays:      req.MinNoticeDays,
+		CountWeekends:      req.CountWeekends,
+		CountHolidays:      req.CountHolidays,
+		SandwichRule:       req.SandwichRule,
+		RequiresDocument:   req.RequiresDocument,
+		DocumentAfterDays:  req.DocumentAfterDays,
+		AccrualFrequency:   normalizeAccrualFrequency(req.AccrualFrequency),
+
+		CarryForwardCap:          req.CarryForwardCap,
+		CarryForwardExpiryMonths: req.CarryForwardExpiryMonths,
+		MaxEncashableDays:        req.MaxEncashableDays,
+	}
+}
+
+// loadLeaveProfile reads the user's role and, when present, the department and
+// location from their employee record.
+func loadLeaveProfile(userID uint) leaveProfile {
+	var row struct {
+		Role         string
+		DepartmentID *uint
+		Location     *string
+	}
+	config.DB.Table("users u").
+		Select("u.role, e.department_id, e.location").
+		Joins("LEFT JOIN employees e ON e.user_id = u.id").
+		Where("u.id = ?", userID).
+		Limit(1).
+		Scan(&row)
+
+	p := leaveProfile{UserID: userID, Role: row.Role}
+	if row.DepartmentID != nil {
+		p.DepartmentID = *row.DepartmentID
+	}
+	if row.Location != nil {
+		p.Location = *row.Location
+	}
+	return p
+}
+
+// policyMatches reports whether the policy applies to the profile and, if so,
+// how specific the match is (number of eligibility filters that are set).
+func policyMatches(policy models.LeavePolicy, p leaveProfile) (bool, int) {
+	score := 0
+	if policy.Role != "" {
+		if !strings.EqualFold(policy.Role, p.Role) {
+			return false, 0
+		}
+		score++
+	}
+	if policy.DepartmentID != nil {
+		if *policy.DepartmentID != p.DepartmentID {
+			return false, 0
+		}
+		score++
+	}
+	if policy.Location != "" {
+		if !strings.EqualFold(policy.Location, strings.TrimSpace(p.Location)) {
+			return false, 0
+		}
+		score++
+	}
+	return true, score
+}
+
+// bestPolicy picks the most specific matching policy; ties go to the oldest one.
+func bestPolicy(policies []models.LeavePolicy, p leaveProfile) *models.LeavePolicy {
+	var best *models.LeavePolicy
+	bestScore := -1
+	for i := range policies {
+		ok, score := policyMatches(policies[i], p)
+		if !ok {
+			continue
+		}
+		if score > bestScore || (score == bestScore && policies[i].ID < best.ID) {
+			best = &policies[i]
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// resolveLeavePolicy returns the policy that applies to the profile for a
+// leave type code, or nil when the type is unknown, inactive, or the employee
+// is not eligible for it.
+func resolveLeavePolicy(p leaveProfile, leaveType string) (*models.LeavePolicy, error) {
+	var lt models.LeaveType
+	err := config.DB.Preload("Policies").
+		Where("code = ? AND active = ?", strings.ToLower(leaveType), true).
+		First(&lt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bestPolicy(lt.Policies, p), nil
+}
+
+// eligibleLeaveTypes lists the active leave types the profile is eligible for.
+func eligibleLeaveTypes(p leaveProfile) ([]EligibleLeaveType, error) {
+	var types []models.LeaveType
+	if err := config.DB.Preload("Policies").
+		Where("active = ?", true).
+		Order("code asc").
+		Find(&types).Error; err != nil {
+		return nil, err
+	}
+
+	result := []EligibleLeaveType{}
+	for _, lt := range types {
+		if policy := bestPolicy(lt.Policies, p); policy != nil {
+			result = append(result, EligibleLeaveType{Code: lt.Code, Name: lt.Name, Policy: *policy})
+		}
+	}
+	return result, nil
+}
+
+// documentRequired reports whether a request of the given length needs supporting documentation.
+func documentRequired(policy *models.LeavePolicy, days float64) bool {
+	return policy.RequiresDocument && days > float64(policy.DocumentAfterDays)
+}
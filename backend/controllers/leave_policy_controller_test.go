@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"peoplesoft/models"
+	"testing"
+)
+
+func TestBestPolicy(t *testing.T) {
+	eng, ops := uint(1), uint(2)
+	policies := []models.LeavePolicy{
+		{ID: 10},
+		{ID: 11, Role: "manager"},
+		{ID: 12, DepartmentID: &eng},
+		{ID: 13, DepartmentID: &eng, Location: "Pune"},
+		{ID: 14, Role: "manager", DepartmentID: &eng},
+		{ID: 15, DepartmentID: &ops},
+		{ID: 16, Location: "pune"},
+	}
+
+	tests := []struct {
+		name     string
+		policies []models.LeavePolicy
+		profile  leaveProfile
+		want     uint // 0 for no policy
+	}{
+		{"only the catch-all matches", policies, leaveProfile{Role: "employee", DepartmentID: 9}, 10},
+		{"role beats the catch-all", policies, leaveProfile{Role: "Manager", DepartmentID: 9}, 11},
+		{"more filters win", policies, leaveProfile{Role: "employee", DepartmentID: eng, Location: " PUNE "}, 13},
+		{"ties go to the oldest policy", policies, leaveProfile{Role: "manager", DepartmentID: eng, Location: "Pune"}, 13},
+		{"department and role", policies, leaveProfile{Role: "manager", DepartmentID: eng}, 14},
+		{"ties between single filters go to the oldest policy", policies, leaveProfile{Role: "employee", DepartmentID: ops, Location: "Pune"}, 15},
+		{"no eligible policy", policies[1:2], leaveProfile{Role: "employee"}, 0},
+		{"no policies", nil, leaveProfile{Role: "hr"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bestPolicy(tt.policies, tt.profile)
+			switch {
+			case tt.want == 0 && got != nil:
+				t.Errorf("bestPolicy = policy %d, want none", got.ID)
+			case tt.want != 0 && got == nil:
+				t.Errorf("bestPolicy = none, want policy %d", tt.want)
+			case got != nil && got.ID != tt.want:
+				t.Errorf("bestPolicy = policy %d, want %d", got.ID, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,488 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UtilisationRow is approved leave taken by a department, type and month.
+type UtilisationRow struct {
+	Department string  `json:"department"`
+	Type       string  `json:"type"`
+	Month      string  `json:"month"` // "YYYY-MM"; a leave spanning months counts in each with its share of days
+	Leaves     int     `json:"leaves"`
+	Employees  int     `json:"employees"`
+	Days       float64 `json:"days"`
+}
+
+// AbsenteeismRow is one employee's absence over the report period.
+// Bradford factor = spells² × days.
+type AbsenteeismRow struct {
+	UserID      uint    `json:"user_id"`
+	Name        string  `json:"name"`
+	Department  string  `json:"department"`
+	WorkingDays float64 `json:"working_days"`
+	DaysAbsent  float64 `json:"days_absent"`
+	Spells      int     `json:"spells"`
+	Rate        float64 `json:"rate"` // percent of working days
+	Bradford    float64 `json:"bradford_factor"`
+}
+
+// LiabilityRow is an allocation's unused balance.
+type LiabilityRow struct {
+	UserID     uint    `json:"user_id"`
+	Name       string  `json:"name"`
+	Department string  `json:"department"`
+	Type       string  `json:"type"`
+	Total      float64 `json:"total"`
+	Used       float64 `json:"used"`
+	Unused     float64 `json:"unused"`
+}
+
+// reportTable is a report flattened for CSV/XLSX export.
+type reportTable struct {
+	Name   string // file name without extension
+	Header []string
+	Rows   [][]any
+	Totals gin.H // extra fields of the JSON response, e.g. grand totals
+}
+
+// reportFilters are the query parameters shared by the leave reports.
+type reportFilters struct {
+	Year         int
+	DepartmentID *uint
+	Type         string
+}
+
+func parseReportFilters(c *gin.Context) (reportFilters, error) {
+	f := reportFilters{Type: strings.ToLower(strings.TrimSpace(c.Query("type")))}
+
+	year, err := strconv.Atoi(c.DefaultQuery("year", strconv.Itoa(time.Now().Year())))
+	if err != nil {
+		return f, fmt.Errorf("invalid year")
+	}
+	f.Year = year
+
+	if v := c.Query("department_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return f, fmt.Errorf("invalid department_id")
+		}
+		d := uint(id)
+		f.DepartmentID = &d
+	}
+	return f, nil
+}
+
+// scope applies the department filter to a query joined with employees e.
+func (f reportFilters) scope(q *gorm.DB) *gorm.DB {
+	if f.DepartmentID != nil {
+		q = q.Where("e.department_id = ?", *f.DepartmentID)
+	}
+	return q
+}
+
+// respondReport answers with JSON (default) or, with ?format=csv|xlsx, a file download.
+func respondReport(c *gin.Context, data any, table reportTable) {
+	writeReport(c, strings.ToLower(c.DefaultQuery("format", "json")), data, table)
+}
+
+func writeReport(c *gin.Context, format string, data any, table reportTable) {
+	if format == "json" {
+		resp := gin.H{"data": data}
+		for k, v := range table.Totals {
+			resp[k] = v
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	if !validReportFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv or xlsx"})
+		return
+	}
+	file, contentType, err := buildReportFile(format, table)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build spreadsheet"})
+		return
+	}
+	sendReportFile(c, format, contentType, file, table)
+}
+
+func validReportFormat(format string) bool {
+	return format == "json" || format == "csv" || format == "xlsx"
+}
+
+// buildReportFile renders the table as a csv or xlsx file.
+func buildReportFile(format string, table reportTable) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if format == "xlsx" {
+		if err := utils.WriteXLSX(&buf, table.Name, table.Header, table.Rows); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), utils.XLSXContentType, nil
+	}
+	w := csv.NewWriter(&buf)
+	_ = w.Write(table.Header)
+	for _, r := range table.Rows {
+		cells := make([]string, len(r))
+		for i, v := range r {
+			cells[i] = utils.CellString(v)
+		}
+		_ = w.Write(cells)
+	}
+	w.Flush()
+	return buf.Bytes(), "text/csv; charset=utf-8", w.Error()
+}
+
+func sendReportFile(c *gin.Context, format, contentType string, file []byte, table reportTable) {
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, table.Name, format))
+	c.Data(http.StatusOK, contentType, file)
+}
+
+func requireReportAccess(c *gin.Context) bool {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can view leave reports"})
+		return false
+	}
+	return true
+}
+
+// GET /api/leaves/reports/utilisation?year=&department_id=&type=&format= (HR only)
+func LeaveUtilisationReport(c *gin.Context) {
+	if !requireReportAccess(c) {
+		return
+	}
+	f, err := parseReportFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	yearStart := time.Date(f.Year, 1, 1, 0, 0, 0, 0, time.UTC)
+	yearEnd := time.Date(f.Year, 12, 31, 0, 0, 0, 0, time.UTC)
+	q := config.DB.Table("leaves l").
+		Select(`l.id, l.user_id, COALESCE(d.name, 'Unassigned') AS department, e.location,
+			l.type, l.start_date, l.end_date, l.portion, l.hours`).
+		Joins("LEFT JOIN employees e ON e.user_id = l.user_id").
+		Joins("LEFT JOIN departments d ON d.id = e.department_id").
+		Where("l.status = ? AND l.start_date <= ? AND l.end_date >= ?", "approved", yearEnd, yearStart)
+	q = f.scope(q)
+	if f.Type != "" {
+		q = q.Where("l.type = ?", f.Type)
+	}
+
+	var leaves []struct {
+		ID         uint
+		UserID     uint
+		Department string
+		Location   *string
+		Type       string
+		StartDate  time.Time
+		EndDate    time.Time
+		Portion    string
+		Hours      float64
+	}
+	if err := q.Scan(&leaves).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build utilisation report"})
+		return
+	}
+
+	// what each leave holds against its allocation, as leaveChargedDays
+	// reads it; leaves without ledger rows are counted from the calendar below
+	ids := make([]uint, len(leaves))
+	for i, l := range leaves {
+		ids[i] = l.ID
+	}
+	var nets []struct {
+		LeaveID uint
+		Net     float64
+	}
+	if len(ids) > 0 {
+		if err := config.DB.Model(&models.LeaveTransaction{}).
+			Select("leave_id, COALESCE(SUM(days), 0) AS net").
+			Where("leave_id IN ?", ids).
+			Group("leave_id").
+			Scan(&nets).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build utilisation report"})
+			return
+		}
+	}
+	charged := make(map[uint]float64, len(nets))
+	for _, n := range nets {
+		charged[n.LeaveID] = -n.Net
+	}
+
+	// holidays of every office over the span of the leaves, for the month split
+	from, to := yearStart, yearEnd
+	for _, l := range leaves {
+		from, to = minTime(from, l.StartDate), maxTime(to, l.EndDate)
+	}
+	holidaysAt := map[string]map[string]bool{}
+
+	byKey := map[string]*UtilisationRow{}
+	employees := map[string]map[uint]bool{}
+	for _, l := range leaves {
+		location := ""
+		if l.Location != nil {
+			location = *l.Location
+		}
+		key := strings.ToLower(strings.TrimSpace(location))
+		holidays, ok := holidaysAt[key]
+		if !ok {
+			holidays = holidaysBetween(location, from, to)
+			holidaysAt[key] = holidays
+		}
+
+		total, ok := charged[l.ID]
+		if !ok {
+			total = roundDays(workingDaysBetween(l.StartDate, l.EndDate, holidays) * portionFraction(l.Portion, l.Hours))
+		}
+
+		for month, days := range splitDaysByMonth(l.StartDate, l.EndDate, total, holidays) {
+			if days <= 0 || !strings.HasPrefix(month, strconv.Itoa(f.Year)+"-") {
+				continue
+			}
+			k := l.Department + "|" + l.Type + "|" + month
+			row, ok := byKey[k]
+			if !ok {
+				row = &UtilisationRow{Department: l.Department, Type: l.Type, Month: month}
+				byKey[k] = row
+				employees[k] = map[uint]bool{}
+			}
+			row.Leaves++
+			row.Days += days
+			employees[k][l.UserID] = true
+		}
+	}
+
+	rows := make([]UtilisationRow, 0, len(byKey))
+	for k, row := range byKey {
+		row.Employees = len(employees[k])
+		row.Days = roundDays(row.Days)
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Department != b.Department {
+			return a.Department < b.Department
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Month < b.Month
+	})
+
+	table := reportTable{
+		Name:   fmt.Sprintf("leave-utilisation-%d", f.Year),
+		Header: []string{"Department", "Type", "Month", "Leaves", "Employees", "Days"},
+	}
+	for _, r := range rows {
+		table.Rows = append(table.Rows, []any{r.Department, r.Type, r.Month, r.Leaves, r.Employees, r.Days})
+	}
+	respondReport(c, rows, table)
+}
+
+// splitDaysByMonth spreads a leave's charged days over the months of
+// [start, end] ("YYYY-MM") in proportion to the working days in each, or to
+// the calendar days when the leave has no working days (weekend-only leave).
+func splitDaysByMonth(start, end time.Time, days float64, holidays map[string]bool) map[string]float64 {
+	type part struct{ working, calendar float64 }
+	parts := map[string]*part{}
+	totalWorking, totalCalendar := 0.0, 0.0
+	for cur := start; !cur.After(end); {
+		monthEnd := time.Date(cur.Year(), cur.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+		last := minTime(monthEnd, end)
+		p := &part{
+			working:  workingDaysBetween(cur, last, holidays),
+			calendar: last.Sub(cur).Hours()/24 + 1,
+		}
+		parts[cur.Format("2006-01")] = p
+		totalWorking += p.working
+		totalCalendar += p.calendar
+		cur = monthEnd.AddDate(0, 0, 1)
+	}
+
+	split := make(map[string]float64, len(parts))
+	for month, p := range parts {
+		if totalWorking > 0 {
+			split[month] = days * p.working / totalWorking
+		} else {
+			split[month] = days * p.calendar / totalCalendar
+		}
+	}
+	return split
+}
+
+// GET /api/leaves/reports/absenteeism?from=&to=&department_id=&type=&format= (HR only)
+// Defaults to the current year to date. Pass type=sick for the usual
+// unplanned-absence Bradford factor.
+func AbsenteeismReport(c *gin.Context) {
+	if !requireReportAccess(c) {
+		return
+	}
+	f, err := parseReportFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	from, to := time.Date(today.Year(), 1, 1, 0, 0, 0, 0, time.UTC), today
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to cannot be before from"})
+		return
+	}
+
+	var people []struct {
+		UserID     uint
+		Name       string
+		Department string
+		Location   string
+	}
+	if err := f.scope(config.DB.Table("employees e").
+		Select("e.user_id, u.name, COALESCE(d.name, 'Unassigned') AS department, e.location").
+		Joins("JOIN users u ON u.id = e.user_id").
+		Joins("LEFT JOIN departments d ON d.id = e.department_id")).
+		Order("u.name asc").
+		Scan(&people).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load employees"})
+		return
+	}
+
+	var leaves []struct {
+		UserID    uint
+		StartDate time.Time
+		EndDate   time.Time
+		Portion   string
+		Hours     float64
+	}
+	lq := config.DB.Table("leaves").
+		Select("user_id, start_date, end_date, portion, hours").
+		Where("status = ? AND start_date <= ? AND end_date >= ?", "approved", to, from)
+	if f.Type != "" {
+		lq = lq.Where("type = ?", f.Type)
+	}
+	if err := lq.Scan(&leaves).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load leaves"})
+		return
+	}
+
+	holidaysAt := map[string]map[string]bool{}
+	rows := make([]AbsenteeismRow, 0, len(people))
+	for _, p := range people {
+		key := strings.ToLower(strings.TrimSpace(p.Location))
+		holidays, ok := holidaysAt[key]
+		if !ok {
+			holidays = holidaysBetween(p.Location, from, to)
+			holidaysAt[key] = holidays
+		}
+
+		row := AbsenteeismRow{
+			UserID:      p.UserID,
+			Name:        p.Name,
+			Department:  p.Department,
+			WorkingDays: workingDaysBetween(from, to, holidays),
+		}
+		for _, l := range leaves {
+			if l.UserID != p.UserID {
+				continue
+			}
+			start, end := l.StartDate, l.EndDate
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+			days := workingDaysBetween(start, end, holidays) * portionFraction(l.Portion, l.Hours)
+			if days <= 0 {
+				continue
+			}
+			row.Spells++
+			row.DaysAbsent += days
+		}
+		row.DaysAbsent = roundDays(row.DaysAbsent)
+		if row.WorkingDays > 0 {
+			row.Rate = roundDays(row.DaysAbsent / row.WorkingDays * 100)
+		}
+		row.Bradford = roundDays(float64(row.Spells*row.Spells) * row.DaysAbsent)
+		rows = append(rows, row)
+	}
+
+	table := reportTable{
+		Name:   fmt.Sprintf("absenteeism-%s-to-%s", from.Format("2006-01-02"), to.Format("2006-01-02")),
+		Header: []string{"User ID", "Name", "Department", "Working days", "Days absent", "Spells", "Absence rate %", "Bradford factor"},
+	}
+	for _, r := range rows {
+		table.Rows = append(table.Rows, []any{r.UserID, r.Name, r.Department, r.WorkingDays, r.DaysAbsent, r.Spells, r.Rate, r.Bradford})
+	}
+	respondReport(c, rows, table)
+}
+
+// GET /api/leaves/reports/liability?year=&department_id=&type=&format= (HR only)
+// Unused leave per employee and type for the year.
+func LeaveLiabilityReport(c *gin.Context) {
+	if !requireReportAccess(c) {
+		return
+	}
+	f, err := parseReportFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	q := config.DB.Table("leave_allocations a").
+		Select(`a.user_id, u.name, COALESCE(d.name, 'Unassigned') AS department, a.type,
+			a.total, a.used, GREATEST(a.total - a.used, 0) AS unused`).
+		Joins("JOIN users u ON u.id = a.user_id").
+		Joins("LEFT JOIN employees e ON e.user_id = a.user_id").
+		Joins("LEFT JOIN departments d ON d.id = e.department_id").
+		Where("a.year = ?", f.Year)
+	q = f.scope(q)
+	if f.Type != "" {
+		q = q.Where("a.type = ?", f.Type)
+	}
+
+	rows := []LiabilityRow{}
+	if err := q.Order("department, u.name, a.type").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build liability report"})
+		return
+	}
+
+	table := reportTable{
+		Name:   fmt.Sprintf("leave-liability-%d", f.Year),
+		Header: []string{"User ID", "Name", "Department", "Type", "Total", "Used", "Unused"},
+	}
+	totalUnused := 0.0
+	for _, r := range rows {
+		totalUnused += r.Unused
+		table.Rows = append(table.Rows, []any{r.UserID, r.Name, r.Department, r.Type, r.Total, r.Used, r.Unused})
+	}
+	table.Totals = gin.H{"total_unused": roundDays(totalUnused)}
+	respondReport(c, rows, table)
+}
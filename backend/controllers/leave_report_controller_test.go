@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSplitDaysByMonth(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end time.Time
+		days       float64
+		holidays   map[string]bool
+		want       map[string]float64
+	}{
+		{
+			name:  "within one month",
+			start: ymd(2025, time.March, 3), end: ymd(2025, time.March, 7), days: 5,
+			want: map[string]float64{"2025-03": 5},
+		},
+		{
+			// Thu 30 Jan – Tue 4 Feb: two working days in each month
+			name:  "across a month end",
+			start: ymd(2025, time.January, 30), end: ymd(2025, time.February, 4), days: 4,
+			want: map[string]float64{"2025-01": 2, "2025-02": 2},
+		},
+		{
+			name:  "holidays shift the share",
+			start: ymd(2025, time.January, 30), end: ymd(2025, time.February, 4), days: 3,
+			holidays: map[string]bool{"2025-01-31": true},
+			want:     map[string]float64{"2025-01": 1, "2025-02": 2},
+		},
+		{
+			// Fri 31 Jan – Mon 3 Feb with the weekend charged
+			name:  "charged weekend follows the working days",
+			start: ymd(2025, time.January, 31), end: ymd(2025, time.February, 3), days: 4,
+			want: map[string]float64{"2025-01": 2, "2025-02": 2},
+		},
+		{
+			name:  "across the year end",
+			start: ymd(2024, time.December, 30), end: ymd(2025, time.January, 3), days: 5,
+			want: map[string]float64{"2024-12": 2, "2025-01": 3},
+		},
+		{
+			name:  "weekend only leave splits by calendar days",
+			start: ymd(2025, time.May, 31), end: ymd(2025, time.June, 1), days: 2,
+			want: map[string]float64{"2025-05": 1, "2025-06": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitDaysByMonth(tt.start, tt.end, tt.days, tt.holidays)
+			if len(got) != len(tt.want) {
+				t.Fatalf("splitDaysByMonth = %v, want %v", got, tt.want)
+			}
+			for month, want := range tt.want {
+				if math.Abs(got[month]-want) > 1e-9 {
+					t.Errorf("%s: %v days, want %v", month, got[month], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RolloverSummary reports what a year-end rollover did.
+type RolloverSummary struct {
+	FromYear    int     `json:"from_year"`
+	Allocations int     `json:"allocations"`
+	Skipped     int     `json:"skipped"` // already rolled over
+	Carried     float64 `json:"carried"`
+	Lapsed      float64 `json:"lapsed"`
+}
+
+// RunLeaveRollover carries each allocation's unused days from fromYear into
+// the next year, up to the policy's carry-forward cap, and lapses the rest.
+// Allocations that were already rolled over are skipped.
+func RunLeaveRollover(fromYear int) (RolloverSummary, error) {
+	summary := RolloverSummary{FromYear: fromYear}
+
+	var allocs []models.LeaveAllocation
+	if err := config.DB.Where("year = ?", fromYear).Order("user_id, type").Find(&allocs).Error; err != nil {
+		return summary, err
+	}
+
+	for _, alloc := range allocs {
+		var existing models.LeaveCarryForward
+		err := config.DB.
+			Where("user_id = ? AND type = ? AND from_year = ?", alloc.UserID, alloc.Type, fromYear).
+			First(&existing).Error
+		if err == nil {
+			summary.Skipped++
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return summary, err
+		}
+
+		record, err := rolloverAllocation(alloc)
+		if err != nil {
+			return summary, fmt.Errorf("rollover for user %d (%s): %w", alloc.UserID, alloc.Type, err)
+		}
+		summary.Allocations++
+		summary.Carried += record.Carried
+		summary.Lapsed += record.Lapsed
+	}
+	return summary, nil
+}
+
+func rolloverAllocation(alloc models.LeaveAllocation) (*models.LeaveCarryForward, error) {
+	policy, err := resolveLeavePolicy(loadLeaveProfile(alloc.UserID), alloc.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	// the old year is closed out to zero: carried days move, the rest lapse
+	var record models.LeaveCarryForward
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// both years stay locked so no booking or refund lands between reading
+		// the unused days and closing the year
+		old, err := lockAllocation(tx, alloc.UserID, alloc.Year, alloc.Type)
+		if err != nil {
+			return err
+		}
+		unused := roundDays(max(old.Total-old.Used, 0))
+
+		carried := 0.0
+		var expiresOn *time.Time
+		if policy != nil {
+			carried = min(unused, float64(policy.CarryForwardCap))
+			if carried > 0 {
+				expiresOn = carryForwardExpiry(policy, alloc.Year)
+			}
+		}
+
+		record = models.LeaveCarryForward{
+			UserID:    alloc.UserID,
+			Type:      alloc.Type,
+			FromYear:  alloc.Year,
+			ToYear:    alloc.Year + 1,
+			Unused:    unused,
+			Carried:   carried,
+			Lapsed:    roundDays(unused - carried),
+			ExpiresOn: expiresOn,
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		if record.Lapsed > 0 {
+			if err := postLeaveTransaction(tx, old, models.LeaveTransaction{
+				Kind:   models.LeaveTxnExpiry,
+				Days:   -record.Lapsed,
+				Reason: "lapsed at year-end",
+			}); err != nil {
+				return err
+			}
+		}
+		if carried <= 0 {
+			return nil
+		}
+		return carryForward(tx, old, carried)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// carryForwardExpiry is the last day of the policy's expiry month in the year
+// after fromYear, or nil when carried days never expire.
+func carryForwardExpiry(policy *models.LeavePolicy, fromYear int) *time.Time {
+	if policy.CarryForwardExpiryMonths <= 0 {
+		return nil
+	}
+	d := time.Date(fromYear+1, time.Month(policy.CarryForwardExpiryMonths)+1, 0, 0, 0, 0, 0, time.UTC)
+	return &d
+}
+
+// carryForward moves days from alloc into the next year's allocation of the
+// same type. Runs inside the caller's transaction.
+func carryForward(tx *gorm.DB, alloc *models.LeaveAllocation, days float64) error {
+	next, err := lockAllocation(tx, alloc.UserID, alloc.Year+1, alloc.Type)
+	if err != nil {
+		return err
+	}
+	if err := postLeaveTransaction(tx, alloc, models.LeaveTransaction{
+		Kind:   models.LeaveTxnCarryForward,
+		Days:   -days,
+		Reason: fmt.Sprintf("carried forward to %d", alloc.Year+1),
+	}); err != nil {
+		return err
+	}
+	return postLeaveTransaction(tx, next, models.LeaveTransaction{
+		Kind:   models.LeaveTxnCarryForward,
+		Days:   days,
+		Reason: fmt.Sprintf("carried forward from %d", alloc.Year),
+	})
+}
+
+// rolloverLateRefund settles days credited back to an allocation whose year
+// was already rolled over (a pending leave rejected or withdrawn, or an
+// approved one cancelled, after year-end). They follow the rollover rules:
+// carried into the next year while the cap allows and the carried days have
+// not expired yet, lapsed otherwise. Runs inside the caller's transaction,
+// which holds alloc's lock.
+func rolloverLateRefund(tx *gorm.DB, alloc *models.LeaveAllocation, days float64) error {
+	var record models.LeaveCarryForward
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND type = ? AND from_year = ?", alloc.UserID, alloc.Type, alloc.Year).
+		First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	policy, err := resolveLeavePolicy(loadLeaveProfile(alloc.UserID), alloc.Type)
+	if err != nil {
+		return err
+	}
+	carried := 0.0
+	if policy != nil && record.ExpiredAt == nil {
+		carried = roundDays(min(days, max(float64(policy.CarryForwardCap)-record.Carried, 0)))
+	}
+	lapsed := roundDays(days - carried)
+
+	if lapsed > 0 {
+		if err := postLeaveTransaction(tx, alloc, models.LeaveTransaction{
+			Kind:   models.LeaveTxnExpiry,
+			Days:   -lapsed,
+			Reason: "refund after year-end lapsed",
+		}); err != nil {
+			return err
+		}
+	}
+	updates := map[string]interface{}{
+		"unused":  roundDays(record.Unused + days),
+		"carried": roundDays(record.Carried + carried),
+		"lapsed":  roundDays(record.Lapsed + lapsed),
+	}
+	if carried > 0 {
+		if err := carryForward(tx, alloc, carried); err != nil {
+			return err
+		}
+		if record.ExpiresOn == nil {
+			updates["expires_on"] = carryForwardExpiry(policy, alloc.Year)
+		}
+	}
+	return tx.Model(&record).Updates(updates).Error
+}
+
+// ExpireCarriedForward removes carried-forward days that are still unused once
+// their expiry date has passed. Carried days are treated as the first days
+// taken in the new year, so only the part not covered by usage expires.
+func ExpireCarriedForward(asOf time.Time) error {
+	today := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+
+	var records []models.LeaveCarryForward
+	if err := config.DB.
+		Where("expires_on IS NOT NULL AND expires_on < ? AND expired_at IS NULL", today).
+		Find(&records).Error; err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			var alloc models.LeaveAllocation
+			err := tx.Where("user_id = ? AND year = ? AND type = ?", r.UserID, r.ToYear, r.Type).First(&alloc).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			expired := 0.0
+			if alloc.ID != 0 {
+				expired = roundDays(max(r.Carried-alloc.Used, 0))
+				if expired > 0 {
+					if err := postLeaveTransaction(tx, &alloc, models.LeaveTransaction{
+						Kind:   models.LeaveTxnExpiry,
+						Days:   -expired,
+						Reason: fmt.Sprintf("carried-forward days from %d expired", r.FromYear),
+					}); err != nil {
+						return err
+					}
+				}
+			}
+
+			now := time.Now()
+			return tx.Model(&r).Updates(map[string]any{
+				"expired_days": expired,
+				"expired_at":   now,
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("expiry for user %d (%s): %w", r.UserID, r.Type, err)
+		}
+	}
+	return nil
+}
+
+// POST /api/leaves/rollover (HR only)
+// Body: {"year": 2025} — rolls that (finished) year's allocations into the next.
+func RolloverLeaves(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can run the leave rollover"})
+		return
+	}
+
+	var in struct {
+		Year int `json:"year"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil || in.Year == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "year is required"})
+		return
+	}
+	if in.Year >= time.Now().Year() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "can only roll over a year that has ended"})
+		return
+	}
+
+	summary, err := RunLeaveRollover(in.Year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "rollover failed", "summary": summary})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": summary})
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notify creates the same notification for each of the users.
+func notify(db *gorm.DB, userIDs []uint, kind, title, message string, leaveID *uint) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	items := make([]models.Notification, 0, len(userIDs))
+	for _, id := range userIDs {
+		items = append(items, models.Notification{
+			UserID:  id,
+			Kind:    kind,
+			Title:   title,
+			Message: message,
+			LeaveID: leaveID,
+		})
+	}
+	return db.Create(&items).Error
+}
+
+// GET /api/notifications?unread=true
+func ListNotifications(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	q := config.DB.Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		q = q.Where("read_at IS NULL")
+	}
+
+	var items []models.Notification
+	if err := q.Order("created_at desc").Limit(100).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load notifications"})
+		return
+	}
+
+	var unread int64
+	config.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread)
+
+	c.JSON(http.StatusOK, gin.H{"data": items, "unread": unread})
+}
+
+// PUT /api/notifications/:id/read
+func MarkNotificationRead(c *gin.Context) {
+	res := config.DB.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", c.Param("id"), c.GetUint("userID")).
+		Update("read_at", time.Now())
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update notification"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "read"})
+}
+
+// PUT /api/notifications/read-all
+func MarkAllNotificationsRead(c *gin.Context) {
+	res := config.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", c.GetUint("userID")).
+		Update("read_at", time.Now())
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "read", "updated": res.RowsAffected})
+}
@@ -0,0 +1,351 @@
+package controllers
+
+import (
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"sort"
+	"strconv"
+
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxOrgDepth bounds how far down GET /api/org/tree walks.
+const maxOrgDepth = 20
+
+// OrgNode is an employee in the org chart with their reports nested below.
+type OrgNode struct {
+	ID             uint       `json:"id"`
+	UserID         uint       `json:"user_id"`
+	Name           string     `json:"name"`
+	Email          string     `json:"email"`
+	Designation    string     `json:"designation"`
+	DepartmentID   uint       `json:"department_id"`
+	DepartmentName *string    `json:"department_name"`
+	ManagerID      *uint      `json:"manager_id"`
+	Location       string     `json:"location"`
+	PositionID     *uint      `json:"position_id"`
+	Vacant         bool       `json:"vacant"`                    // an unfilled position rather than a person
+	PositionStatus string     `json:"position_status,omitempty"` // open or frozen, for vacant positions
+	Depth          int        `json:"depth"`
+	DirectReports  int        `json:"direct_reports"`
+	TotalReports   int        `json:"total_reports"` // direct and indirect, regardless of depth
+	Reports        []*OrgNode `json:"reports"`
+}
+
+// SpanOfControl counts a manager's reports.
+type SpanOfControl struct {
+	ID            uint   `json:"id"`
+	Name          string `json:"name"`
+	Designation   string `json:"designation"`
+	DirectReports int    `json:"direct_reports"`
+	TotalReports  int    `json:"total_reports"`
+}
+
+// ChainLink is one manager in an employee's management chain.
+type ChainLink struct {
+	ID          uint   `json:"id"`
+	UserID      uint   `json:"user_id"`
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	Designation string `json:"designation"`
+	Level       int    `json:"level"` // 1 = direct manager
+}
+
+// The recursive queries carry the ids visited so far in path, so a cycle that
+// slipped into employees.manager_id cannot make them loop.
+
+const orgSubtreeSQL = `
+WITH RECURSIVE org AS (
+	SELECT e.id, 0 AS depth, ARRAY[e.id] AS path
+	FROM employees e
+	WHERE (?::bigint IS NULL AND e.manager_id IS NULL) OR e.id = ?::bigint
+	UNION ALL
+	SELECT c.id, o.depth + 1, o.path || c.id
+	FROM employees c
+	JOIN org o ON c.manager_id = o.id
+	WHERE o.depth < ? AND NOT c.id = ANY(o.path)
+)
+SELECT e.id, e.user_id, u.name, u.email, e.designation, e.department_id, d.name AS department_name,
+	e.manager_id, e.location, e.position_id, org.depth
+FROM org
+JOIN employees e ON e.id = org.id
+JOIN users u ON u.id = e.user_id
+LEFT JOIN departments d ON d.id = e.department_id
+ORDER BY org.depth, u.name`
+
+const spanOfControlSQL = `
+WITH RECURSIVE reports AS (
+	SELECT e.manager_id AS manager_id, e.id AS report_id, ARRAY[e.id] AS path
+	FROM employees e
+	WHERE e.manager_id IS NOT NULL
+	UNION ALL
+	SELECT m.manager_id, r.report_id, r.path || m.id
+	FROM reports r
+	JOIN employees m ON m.id = r.manager_id
+	WHERE m.manager_id IS NOT NULL AND NOT m.id = ANY(r.path)
+)
+SELECT r.manager_id AS id, u.name, e.designation,
+	(SELECT COUNT(*) FROM employees d WHERE d.manager_id = r.manager_id) AS direct_reports,
+	COUNT(DISTINCT r.report_id) AS total_reports
+FROM reports r
+JOIN employees e ON e.id = r.manager_id
+JOIN users u ON u.id = e.user_id
+WHERE r.report_id <> r.manager_id
+GROUP BY r.manager_id, u.name, e.designation`
+
+const managementChainSQL = `
+WITH RECURSIVE chain AS (
+	SELECT e.id, e.manager_id, 0 AS level, ARRAY[e.id] AS path
+	FROM employees e
+	WHERE e.id = ?
+	UNION ALL
+	SELECT m.id, m.manager_id, c.level + 1, c.path || m.id
+	FROM employees m
+	JOIN chain c ON m.id = c.manager_id
+	WHERE NOT m.id = ANY(c.path)
+)
+SELECT e.id, e.user_id, u.name, u.email, e.designation, chain.level
+FROM chain
+JOIN employees e ON e.id = chain.id
+JOIN users u ON u.id = e.user_id
+WHERE chain.level > 0
+ORDER BY chain.level`
+
+// spanOfControl returns every manager's report counts, keyed by employee id.
+func spanOfControl() (map[uint]SpanOfControl, error) {
+	var rows []SpanOfControl
+	if err := config.DB.Raw(spanOfControlSQL).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	spans := make(map[uint]SpanOfControl, len(rows))
+	for _, r := range rows {
+		spans[r.ID] = r
+	}
+	return spans, nil
+}
+
+// managementChain lists the managers above an employee, nearest first.
+func managementChain(db *gorm.DB, employeeID uint) ([]ChainLink, error) {
+	links := []ChainLink{}
+	err := db.Raw(managementChainSQL, employeeID).Scan(&links).Error
+	return links, err
+}
+
+// wouldCreateCycle reports whether making managerID the manager of employeeID
+// would put the employee above themselves in the hierarchy.
+func wouldCreateCycle(db *gorm.DB, employeeID, managerID uint) (bool, error) {
+	if employeeID == managerID {
+		return true, nil
+	}
+	var all []models.Employee
+	if err := db.Select("id, manager_id").Find(&all).Error; err != nil {
+		return false, err
+	}
+	managers := make(map[uint]*uint, len(all))
+	for _, e := range all {
+		managers[e.ID] = e.ManagerID
+	}
+	return managerChainReaches(managers, employeeID, managerID), nil
+}
+
+// managerChainReaches reports whether employeeID is managerID or one of the
+// managers above it, following managers (employee id -> manager id). A loop
+// already in the data ends the walk.
+func managerChainReaches(managers map[uint]*uint, employeeID, managerID uint) bool {
+	seen := map[uint]bool{}
+	for at := &managerID; at != nil && !seen[*at]; at = managers[*at] {
+		if *at == employeeID {
+			return true
+		}
+		seen[*at] = true
+	}
+	return false
+}
+
+// addVacantPositions hangs each open or frozen position under the node of the
+// position it reports to, vacancies under vacancies included, and returns the
+// roots with vacancies that report to no position added when wholeOrg is set.
+// Vacancies deeper than depth are left out.
+func addVacantPositions(roots, nodes []*OrgNode, wholeOrg bool, depth int) ([]*OrgNode, int, error) {
+	var vacant []PositionRow
+	if err := positionQuery().Where("p.status <> ?", models.PositionFilled).Order("p.title asc").Scan(&vacant).Error; err != nil {
+		return nil, 0, err
+	}
+
+	byPosition := map[uint]*OrgNode{}
+	for _, n := range nodes {
+		if n.PositionID != nil {
+			byPosition[*n.PositionID] = n
+		}
+	}
+	boxes := make([]*OrgNode, len(vacant))
+	for i, p := range vacant {
+		id := p.ID
+		boxes[i] = &OrgNode{
+			Name:           p.Title,
+			Designation:    p.Title,
+			DepartmentID:   p.DepartmentID,
+			DepartmentName: p.DepartmentName,
+			PositionID:     &id,
+			Vacant:         true,
+			PositionStatus: p.Status,
+			Reports:        []*OrgNode{},
+		}
+		byPosition[id] = boxes[i]
+	}
+	for i, p := range vacant {
+		if p.ReportsToID == nil {
+			if wholeOrg {
+				roots = append(roots, boxes[i])
+			}
+			continue
+		}
+		if parent, ok := byPosition[*p.ReportsToID]; ok {
+			parent.Reports = append(parent.Reports, boxes[i])
+		}
+	}
+
+	// vacancies only get a depth once they hang from a placed node
+	placed := 0
+	var walk func(n *OrgNode) bool
+	walk = func(n *OrgNode) bool {
+		if n.Vacant {
+			if n.Depth > depth {
+				return false
+			}
+			placed++
+		}
+		kept := n.Reports[:0]
+		for _, r := range n.Reports {
+			if r.Vacant {
+				r.Depth = n.Depth + 1
+			}
+			if walk(r) {
+				kept = append(kept, r)
+			}
+		}
+		n.Reports = kept
+		return true
+	}
+	for _, r := range roots {
+		walk(r)
+	}
+	return roots, placed, nil
+}
+
+// GET /api/org/tree?root=&depth=&vacancies=true
+// The hierarchy below the employee with id root or, without root, below
+// everyone who has no manager. depth limits the levels returned (default and
+// maximum 20). vacancies adds open and frozen positions as vacant boxes under
+// the holder of the position they report to.
+func GetOrgTree(c *gin.Context) {
+	var root *uint64
+	if v := c.Query("root"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid root"})
+			return
+		}
+		root = &id
+	}
+	depth := maxOrgDepth
+	if v := c.Query("depth"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "depth must be a non-negative number"})
+			return
+		}
+		depth = min(d, maxOrgDepth)
+	}
+
+	var nodes []*OrgNode
+	if err := config.DB.Raw(orgSubtreeSQL, root, root, depth).Scan(&nodes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load org chart"})
+		return
+	}
+	if root != nil && len(nodes) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "employee not found"})
+		return
+	}
+
+	spans, err := spanOfControl()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count reports"})
+		return
+	}
+
+	// rows come parents first, so every manager is indexed before their reports
+	byID := make(map[uint]*OrgNode, len(nodes))
+	roots := []*OrgNode{}
+	for _, n := range nodes {
+		n.Reports = []*OrgNode{}
+		n.DirectReports, n.TotalReports = spans[n.ID].DirectReports, spans[n.ID].TotalReports
+		byID[n.ID] = n
+		if n.Depth == 0 {
+			roots = append(roots, n)
+			continue
+		}
+		if parent, ok := byID[*n.ManagerID]; ok {
+			parent.Reports = append(parent.Reports, n)
+		}
+	}
+
+	if c.Query("vacancies") != "true" {
+		c.JSON(http.StatusOK, gin.H{"data": roots, "count": len(nodes)})
+		return
+	}
+	roots, vacancies, err := addVacantPositions(roots, nodes, root == nil, depth)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load positions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": roots, "count": len(nodes), "vacancies": vacancies})
+}
+
+// GET /api/org/span-of-control
+// Direct and total reports of every employee who manages someone, widest first.
+func GetSpanOfControl(c *gin.Context) {
+	spans, err := spanOfControl()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count reports"})
+		return
+	}
+	rows := make([]SpanOfControl, 0, len(spans))
+	for _, s := range spans {
+		rows = append(rows, s)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].DirectReports != rows[j].DirectReports {
+			return rows[i].DirectReports > rows[j].DirectReports
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// GET /api/employees/:id/chain
+// The employee's managers up to the top of the organisation, nearest first.
+func GetManagementChain(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid employee id"})
+		return
+	}
+
+	var exists int64
+	config.DB.Table("employees").Where("id = ?", id).Count(&exists)
+	if exists == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+
+	chain, err := managementChain(config.DB, uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load management chain"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": chain})
+}
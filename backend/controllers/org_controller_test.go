@@ -0,0 +1,43 @@
+package controllers
+
+import "testing"
+
+func TestManagerChainReaches(t *testing.T) {
+	id := func(v uint) *uint { return &v }
+	// 1 is the CEO; 2 and 3 report to 1; 4 reports to 2; 5 reports to 4.
+	// 6 and 7 manage each other, a loop already in the data.
+	managers := map[uint]*uint{
+		1: nil,
+		2: id(1),
+		3: id(1),
+		4: id(2),
+		5: id(4),
+		6: id(7),
+		7: id(6),
+	}
+
+	tests := []struct {
+		name       string
+		employeeID uint
+		managerID  uint
+		want       bool
+	}{
+		{"self-manager", 4, 4, true},
+		{"direct loop: manager reports to the employee", 4, 5, true},
+		{"indirect loop: manager is two levels below", 2, 5, true},
+		{"the CEO under anyone", 1, 3, true},
+		{"report to own manager's manager", 5, 2, false},
+		{"move to a sibling branch", 4, 3, false},
+		{"report to the CEO", 3, 1, false},
+		{"unknown manager", 4, 99, false},
+		{"existing loop elsewhere ends the walk", 1, 6, false},
+		{"existing loop containing the employee", 7, 6, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := managerChainReaches(managers, tt.employeeID, tt.managerID); got != tt.want {
+				t.Errorf("managerChainReaches(%d, %d) = %v, want %v", tt.employeeID, tt.managerID, got, tt.want)
+			}
+		})
+	}
+}
@@ -240,20 +240,23 @@ func AdminReport(c *gin.Context) {
 
 	q := `
 SELECT e.department_id,
+       d.name AS department_name,
        AVG(r.rating)::numeric(10,2) AS avg_rating,
        COUNT(*) AS review_count
 FROM manager_reviews r
 JOIN users u ON u.id = r.employee_id
 JOIN employees e ON e.user_id = u.id
+LEFT JOIN departments d ON d.id = e.department_id
 WHERE ($1::int IS NULL OR r.cycle_id = $1::int)
   AND ($2::int IS NULL OR e.department_id = $2::int)
-GROUP BY e.department_id
+GROUP BY e.department_id, d.name
 ORDER BY e.department_id;`
 
 	var rows []struct {
-		DepartmentID int     `json:"department_id"`
-		AvgRating    float64 `json:"avg_rating"`
-		ReviewCount  int     `json:"review_count"`
+		DepartmentID   int     `json:"department_id"`
+		DepartmentName *string `json:"department_name"`
+		AvgRating      float64 `json:"avg_rating"`
+		ReviewCount    int     `json:"review_count"`
 	}
 	var cID, dID *int
 	if cycle != "" {
@@ -0,0 +1,547 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PositionRequest is the body of position create and update. On update,
+// omitted fields keep their value and a reports_to_id of 0 clears it.
+// status may only be open or frozen; filled follows from assignment.
+type PositionRequest struct {
+	Title        *string  `json:"title"`
+	DepartmentID *uint    `json:"department_id"`
+	ReportsToID  *uint    `json:"reports_to_id"`
+	FTE          *float64 `json:"fte"`
+	Status       *string  `json:"status"`
+}
+
+// PositionRow is a position with its department, reporting position and
+// incumbent resolved.
+type PositionRow struct {
+	ID             uint       `json:"id"`
+	Title          string     `json:"title"`
+	DepartmentID   uint       `json:"department_id"`
+	DepartmentName *string    `json:"department_name"`
+	ReportsToID    *uint      `json:"reports_to_id"`
+	ReportsToTitle *string    `json:"reports_to_title"`
+	FTE            float64    `json:"fte"`
+	Status         string     `json:"status"`
+	VacantSince    *time.Time `json:"vacant_since"`
+	DaysVacant     *int       `json:"days_vacant"`
+	EmployeeID     *uint      `json:"employee_id"`
+	EmployeeName   *string    `json:"employee_name"`
+}
+
+// VacancyRow counts one department's positions.
+type VacancyRow struct {
+	DepartmentID   uint    `json:"department_id"`
+	DepartmentName *string `json:"department_name"`
+	Positions      int     `json:"positions"`
+	Filled         int     `json:"filled"`
+	Open           int     `json:"open"`
+	Frozen         int     `json:"frozen"`
+	BudgetedFTE    float64 `json:"budgeted_fte"`
+	FilledFTE      float64 `json:"filled_fte"`
+	VacancyRate    float64 `json:"vacancy_rate"` // percentage of non-frozen FTE left open
+}
+
+func positionQuery() *gorm.DB {
+	return config.DB.Table("positions p").
+		Select(`p.id, p.title, p.department_id, d.name AS department_name, p.reports_to_id,
+			rp.title AS reports_to_title, p.fte, p.status, p.vacant_since, e.id AS employee_id, u.name AS employee_name`).
+		Joins("LEFT JOIN departments d ON d.id = p.department_id").
+		Joins("LEFT JOIN positions rp ON rp.id = p.reports_to_id").
+		Joins("LEFT JOIN employees e ON e.position_id = p.id").
+		Joins("LEFT JOIN users u ON u.id = e.user_id")
+}
+
+func withDaysVacant(rows []PositionRow) {
+	today := time.Now().Truncate(24 * time.Hour)
+	for i := range rows {
+		if v := rows[i].VacantSince; v != nil {
+			days := int(today.Sub(v.Truncate(24*time.Hour)).Hours() / 24)
+			rows[i].DaysVacant = &days
+		}
+	}
+}
+
+// refreshPositionStatus marks the position filled when someone holds it and
+// open again when its incumbent has gone. Frozen positions stay frozen.
+func refreshPositionStatus(tx *gorm.DB, positionID uint) error {
+	var pos models.Position
+	if err := tx.First(&pos, positionID).Error; err != nil {
+		return err
+	}
+	var holders int64
+	if err := tx.Model(&models.Employee{}).Where("position_id = ?", positionID).Count(&holders).Error; err != nil {
+		return err
+	}
+	switch {
+	case holders > 0 && pos.Status != models.PositionFilled:
+		return tx.Model(&pos).Updates(map[string]interface{}{"status": models.PositionFilled, "vacant_since": nil}).Error
+	case holders == 0 && pos.Status == models.PositionFilled:
+		return tx.Model(&pos).Updates(map[string]interface{}{"status": models.PositionOpen, "vacant_since": time.Now()}).Error
+	}
+	return nil
+}
+
+// positionWouldCycle reports whether making reportsTo the reporting position
+// of id would put the position below itself.
+func positionWouldCycle(id, reportsTo uint) (bool, error) {
+	var all []models.Position
+	if err := config.DB.Select("id, reports_to_id").Find(&all).Error; err != nil {
+		return false, err
+	}
+	parents := make(map[uint]*uint, len(all))
+	for _, p := range all {
+		parents[p.ID] = p.ReportsToID
+	}
+	seen := map[uint]bool{}
+	for at := &reportsTo; at != nil && !seen[*at]; at = parents[*at] {
+		if *at == id {
+			return true, nil
+		}
+		seen[*at] = true
+	}
+	return false, nil
+}
+
+// applyPositionRequest validates req and copies it onto pos.
+func applyPositionRequest(pos *models.Position, req PositionRequest) (int, string) {
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			return http.StatusBadRequest, "title is required"
+		}
+		pos.Title = title
+	}
+	if req.DepartmentID != nil {
+		var dept models.Department
+		if err := config.DB.First(&dept, *req.DepartmentID).Error; err != nil {
+			return http.StatusBadRequest, "department not found"
+		}
+		pos.DepartmentID = dept.ID
+	}
+	if req.ReportsToID != nil {
+		if *req.ReportsToID == 0 {
+			pos.ReportsToID = nil
+		} else {
+			var parent models.Position
+			if err := config.DB.First(&parent, *req.ReportsToID).Error; err != nil {
+				return http.StatusBadRequest, "reporting position not found"
+			}
+			if pos.ID != 0 {
+				cycle, err := positionWouldCycle(pos.ID, parent.ID)
+				if err != nil {
+					return http.StatusInternalServerError, "failed to check reporting line"
+				}
+				if cycle {
+					return http.StatusBadRequest, "a position cannot report to itself, directly or indirectly"
+				}
+			}
+			pos.ReportsToID = &parent.ID
+		}
+	}
+	if req.FTE != nil {
+		if *req.FTE <= 0 || *req.FTE > 1 || math.IsNaN(*req.FTE) {
+			return http.StatusBadRequest, "fte must be more than 0 and at most 1"
+		}
+		pos.FTE = *req.FTE
+	}
+	if req.Status != nil {
+		status := strings.ToLower(strings.TrimSpace(*req.Status))
+		if status != models.PositionOpen && status != models.PositionFrozen {
+			return http.StatusBadRequest, "status must be open or frozen"
+		}
+		if pos.Status == models.PositionFilled {
+			return http.StatusConflict, "a filled position cannot be reopened or frozen; vacate it first"
+		}
+		pos.Status = status
+	}
+	return 0, ""
+}
+
+// GET /api/positions?status=&department_id=
+func ListPositions(c *gin.Context) {
+	q := positionQuery()
+	if s := c.Query("status"); s != "" {
+		q = q.Where("p.status = ?", strings.ToLower(s))
+	}
+	if d := c.Query("department_id"); d != "" {
+		if did, err := strconv.Atoi(d); err == nil {
+			q = q.Where("p.department_id = ?", did)
+		}
+	}
+
+	rows := []PositionRow{}
+	if err := q.Order("d.name asc, p.title asc").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load positions"})
+		return
+	}
+	withDaysVacant(rows)
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// GET /api/positions/open?department_id=
+// Positions available to recruit for, longest vacant first.
+func ListOpenPositions(c *gin.Context) {
+	q := positionQuery().Where("p.status = ?", models.PositionOpen)
+	if d := c.Query("department_id"); d != "" {
+		if did, err := strconv.Atoi(d); err == nil {
+			q = q.Where("p.department_id = ?", did)
+		}
+	}
+
+	rows := []PositionRow{}
+	if err := q.Order("p.vacant_since asc, p.title asc").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load positions"})
+		return
+	}
+	withDaysVacant(rows)
+	c.JSON(http.StatusOK, gin.H{"data": rows, "count": len(rows)})
+}
+
+// GET /api/positions/:id
+func GetPosition(c *gin.Context) {
+	var rows []PositionRow
+	if err := positionQuery().Where("p.id = ?", c.Param("id")).Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "lookup failed"})
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	withDaysVacant(rows)
+	c.JSON(http.StatusOK, gin.H{"data": rows[0]})
+}
+
+// POST /api/positions (HR only)
+// Body: {"title": "Backend Engineer", "department_id": 2, "reports_to_id": 7, "fte": 1, "status": "open"}
+func CreatePosition(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage positions"})
+		return
+	}
+	var req PositionRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Title == nil || req.DepartmentID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title and department_id are required"})
+		return
+	}
+
+	now := time.Now()
+	pos := models.Position{FTE: 1, Status: models.PositionOpen, VacantSince: &now}
+	if status, msg := applyPositionRequest(&pos, req); status != 0 {
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+	if err := config.DB.Create(&pos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create position"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": pos})
+}
+
+// PUT /api/positions/:id (HR only)
+// On a filled position, a new title, department or reporting position is
+// recorded on the holder's job from today (as a reorg), with the holder of
+// the new reporting position as their manager.
+func UpdatePosition(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage positions"})
+		return
+	}
+	var pos models.Position
+	if err := config.DB.First(&pos, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	var req PositionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	errInvalid := errors.New("invalid position request")
+	var status int
+	var msg string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// lock the position so the holder and reporting line read below
+		// cannot change under the edit
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&pos, pos.ID).Error; err != nil {
+			return err
+		}
+		before := pos
+		if status, msg = applyPositionRequest(&pos, req); status != 0 {
+			return errInvalid
+		}
+
+		// the holder's job follows the position
+		var holder models.Employee
+		var change jobChange
+		if pos.Status == models.PositionFilled {
+			if err := tx.Where("position_id = ?", pos.ID).First(&holder).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+		if holder.ID != 0 {
+			if pos.Title != before.Title {
+				change.Designation = &pos.Title
+			}
+			if pos.DepartmentID != before.DepartmentID {
+				change.DepartmentID = &pos.DepartmentID
+			}
+			if pos.ReportsToID != nil && !sameManager(pos.ReportsToID, before.ReportsToID) {
+				var manager models.Employee
+				err := tx.Where("position_id = ?", *pos.ReportsToID).First(&manager).Error
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+				if err == nil {
+					change.ManagerID = &manager.ID
+				}
+			}
+		}
+
+		if err := tx.Save(&pos).Error; err != nil {
+			return err
+		}
+		if change.empty() {
+			return nil
+		}
+		_, err := recordJobChange(tx, holder, change, time.Now().Truncate(24*time.Hour), models.JobActionReorg, "position updated", c.GetUint("userID"))
+		return err
+	})
+	if errors.Is(err, errInvalid) {
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+	if errors.Is(err, errBeforeFirstJob) || errors.Is(err, errManagerCycle) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update position"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": pos})
+}
+
+// DELETE /api/positions/:id (HR only)
+// Only vacant positions that no other position reports to can be deleted.
+func DeletePosition(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage positions"})
+		return
+	}
+	var pos models.Position
+	if err := config.DB.First(&pos, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+
+	var holders, reports int64
+	config.DB.Model(&models.Employee{}).Where("position_id = ?", pos.ID).Count(&holders)
+	config.DB.Model(&models.Position{}).Where("reports_to_id = ?", pos.ID).Count(&reports)
+	if holders > 0 || reports > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":               "vacate the position and move the positions reporting to it first",
+			"filled":              holders > 0,
+			"reporting_positions": reports,
+		})
+		return
+	}
+	if err := config.DB.Delete(&pos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete position"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// PUT /api/positions/:id/assign (HR only)
+// Body: {"employee_id": 12, "action": "transfer", "reason": "..."}
+// Puts the employee in the open position, freeing the one they held. Their
+// job takes the position's title and department from today, and their
+// manager becomes whoever holds the reporting position, if anyone does.
+func AssignPosition(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage positions"})
+		return
+	}
+	var req struct {
+		EmployeeID uint   `json:"employee_id"`
+		Action     string `json:"action"`
+		Reason     string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.EmployeeID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "employee_id is required"})
+		return
+	}
+	action := strings.ToLower(strings.TrimSpace(req.Action))
+	if action == "" {
+		action = models.JobActionTransfer
+	}
+	if !jobActions[action] || action == models.JobActionHire {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be one of promotion, transfer, reorg, data_change"})
+		return
+	}
+
+	var pos models.Position
+	if err := config.DB.First(&pos, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "position not found"})
+		return
+	}
+	var emp models.Employee
+	if err := config.DB.First(&emp, req.EmployeeID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "employee not found"})
+		return
+	}
+	if emp.PositionID != nil && *emp.PositionID == pos.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the employee already holds this position"})
+		return
+	}
+
+	change := jobChange{Designation: &pos.Title, DepartmentID: &pos.DepartmentID}
+	if pos.ReportsToID != nil {
+		var manager models.Employee
+		if err := config.DB.Where("position_id = ?", *pos.ReportsToID).First(&manager).Error; err == nil {
+			cycle, err := wouldCreateCycle(config.DB, emp.ID, manager.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check reporting line"})
+				return
+			}
+			if cycle {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "the holder of the reporting position reports to this employee"})
+				return
+			}
+			change.ManagerID = &manager.ID
+		}
+	}
+
+	errNotOpen := errors.New("position not open")
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// lock the position so two assignments cannot both find it open
+		var locked models.Position
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, pos.ID).Error; err != nil {
+			return err
+		}
+		if locked.Status != models.PositionOpen {
+			return errNotOpen
+		}
+		if err := tx.Model(&emp).Update("position_id", pos.ID).Error; err != nil {
+			return err
+		}
+		if emp.PositionID != nil {
+			if err := refreshPositionStatus(tx, *emp.PositionID); err != nil {
+				return err
+			}
+		}
+		if err := refreshPositionStatus(tx, pos.ID); err != nil {
+			return err
+		}
+		_, err := recordJobChange(tx, emp, change, time.Now().Truncate(24*time.Hour), action, strings.TrimSpace(req.Reason), c.GetUint("userID"))
+		return err
+	})
+	if errors.Is(err, errNotOpen) {
+		c.JSON(http.StatusConflict, gin.H{"error": "only open positions can be assigned"})
+		return
+	}
+	if errors.Is(err, errBeforeFirstJob) || errors.Is(err, errManagerCycle) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to assign position"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "assigned"})
+}
+
+// PUT /api/positions/:id/vacate (HR only)
+// Removes the incumbent from the position, which opens again.
+func VacatePosition(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can manage positions"})
+		return
+	}
+	var pos models.Position
+	if err := config.DB.First(&pos, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Employee{}).Where("position_id = ?", pos.ID).Update("position_id", nil)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return refreshPositionStatus(tx, pos.ID)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the position is already vacant"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to vacate position"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "vacated"})
+}
+
+// GET /api/positions/vacancies?format=json|csv|xlsx (HR only)
+// Filled, open and frozen positions and FTE by department.
+func PositionVacancyReport(c *gin.Context) {
+	if c.GetString("role") != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR can view vacancy reports"})
+		return
+	}
+
+	rows := []VacancyRow{}
+	if err := config.DB.Table("positions p").
+		Select(`p.department_id, d.name AS department_name, COUNT(*) AS positions,
+			COUNT(*) FILTER (WHERE p.status = 'filled') AS filled,
+			COUNT(*) FILTER (WHERE p.status = 'open') AS open,
+			COUNT(*) FILTER (WHERE p.status = 'frozen') AS frozen,
+			COALESCE(SUM(p.fte) FILTER (WHERE p.status <> 'frozen'), 0) AS budgeted_fte,
+			COALESCE(SUM(p.fte) FILTER (WHERE p.status = 'filled'), 0) AS filled_fte`).
+		Joins("LEFT JOIN departments d ON d.id = p.department_id").
+		Group("p.department_id, d.name").
+		Order("d.name asc").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build vacancy report"})
+		return
+	}
+
+	table := reportTable{
+		Name:   "position-vacancies",
+		Header: []string{"Department", "Positions", "Filled", "Open", "Frozen", "Budgeted FTE", "Filled FTE", "Vacancy rate %"},
+	}
+	for i := range rows {
+		r := &rows[i]
+		r.BudgetedFTE, r.FilledFTE = math.Round(r.BudgetedFTE*100)/100, math.Round(r.FilledFTE*100)/100
+		if r.BudgetedFTE > 0 {
+			r.VacancyRate = math.Round((r.BudgetedFTE-r.FilledFTE)/r.BudgetedFTE*1000) / 10
+		}
+		dept := "Unassigned"
+		if r.DepartmentName != nil {
+			dept = *r.DepartmentName
+		}
+		table.Rows = append(table.Rows, []any{dept, r.Positions, r.Filled, r.Open, r.Frozen, r.BudgetedFTE, r.FilledFTE, r.VacancyRate})
+	}
+	respondReport(c, rows, table)
+}
@@ -5,6 +5,8 @@ import (
 	"peoplesoft/config"
 	"peoplesoft/models"
 
+	"gorm.io/gorm"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -32,16 +34,34 @@ func DeleteUser(c *gin.Context) {
 	var emp models.Employee
 	config.DB.Where("user_id = ?", id).First(&emp)
 
-	// Update team members' manager_id to NULL if this person was a manager
-	if emp.ID != 0 {
-		config.DB.Model(&models.Employee{}).Where("manager_id = ?", emp.ID).Update("manager_id", nil)
-	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Update team members' manager_id to NULL if this person was a manager,
+		// and clear them as head of any department
+		if emp.ID != 0 {
+			if err := tx.Model(&models.Employee{}).Where("manager_id = ?", emp.ID).Update("manager_id", nil).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Department{}).Where("head_id = ?", emp.ID).Update("head_id", nil).Error; err != nil {
+				return err
+			}
+		}
+
+		// Delete employee record (will cascade to leaves, goals, reviews via foreign keys)
+		if err := tx.Where("user_id = ?", id).Delete(&models.Employee{}).Error; err != nil {
+			return err
+		}
 
-	// Delete employee record (will cascade to leaves, goals, reviews via foreign keys)
-	config.DB.Where("user_id = ?", id).Delete(&models.Employee{})
+		// Reopen the position they held
+		if emp.PositionID != nil {
+			if err := refreshPositionStatus(tx, *emp.PositionID); err != nil {
+				return err
+			}
+		}
 
-	// Delete user (will cascade to all user-related records)
-	if err := config.DB.Delete(&models.User{}, id).Error; err != nil {
+		// Delete user (will cascade to all user-related records)
+		return tx.Delete(&models.User{}, id).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
 		return
 	}
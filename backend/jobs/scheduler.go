@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"peoplesoft/controllers"
+	"peoplesoft/middleware"
+)
+
+// Start launches the background jobs. Each job runs once at startup and then
+// on its interval; every job is idempotent, so restarts and overlapping
+// instances only repeat work that has already been recorded.
+func Start() {
+	every("leave accruals", 24*time.Hour, func() error {
+		return controllers.RunLeaveAccruals(time.Now())
+	})
+	every("leave year-end rollover", 24*time.Hour, func() error {
+		// no-op once last year's allocations have been rolled over
+		_, err := controllers.RunLeaveRollover(time.Now().Year() - 1)
+		return err
+	})
+	every("carried-forward leave expiry", 24*time.Hour, func() error {
+		return controllers.ExpireCarriedForward(time.Now())
+	})
+	every("comp-off expiry", 24*time.Hour, func() error {
+		return controllers.ExpireCompOffCredits(time.Now())
+	})
+	every("effective-dated job changes", 24*time.Hour, func() error {
+		return controllers.ApplyEffectiveJobChanges(time.Now())
+	})
+	every("pending leave reminders", time.Hour, func() error {
+		return controllers.ProcessPendingLeaves(time.Now())
+	})
+	every("idempotency key cleanup", time.Hour, func() error {
+		return middleware.PruneIdempotencyKeys(time.Now())
+	})
+}
+
+func every(name string, interval time.Duration, job func() error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := job(); err != nil {
+				log.Printf("⚠️ job %q failed: %v", name, err)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -8,9 +8,11 @@ import (
 
 	"peoplesoft/config"
 	"peoplesoft/controllers"
+	"peoplesoft/jobs"
 	"peoplesoft/middleware"
 	"peoplesoft/models"
 	"peoplesoft/routes"
+	"peoplesoft/storage"
 	"peoplesoft/utils"
 )
 
@@ -26,10 +28,17 @@ func main() {
 		log.Fatalf("DB connection failed: %v", err)
 	}
 
+	// The allocation unique index cannot be built over duplicated rows
+	if err := controllers.MergeDuplicateLeaveAllocations(); err != nil {
+		log.Fatalf("Merging duplicate leave allocations failed: %v", err)
+	}
+
 	// Auto migrate models
 	if err := config.DB.AutoMigrate(
 		&models.User{},
 		&models.Employee{},
+		&models.EmployeeJob{},
+		&models.Position{},
 		&models.Department{},
 		&models.Leave{},
 		&models.Performance{},
@@ -38,10 +47,50 @@ func main() {
 		&models.SelfAssessment{},
 		&models.ManagerReview{},
 		&models.LeaveAllocation{},
+		&models.Holiday{},
+		&models.LeaveType{},
+		&models.LeavePolicy{},
+		&models.LeaveAccrual{},
+		&models.LeaveCarryForward{},
+		&models.LeaveTransaction{},
+		&models.CalendarToken{},
+		&models.LeaveApprovalRule{},
+		&models.LeaveApprovalDelegation{},
+		&models.LeaveApproval{},
+		&models.LeaveCancellation{},
+		&models.CompOffRequest{},
+		&models.LeaveAttachment{},
+		&models.Notification{},
+		&models.LeaveReminder{},
+		&models.IdempotencyKey{},
+		&models.LeaveEncashment{},
 	); err != nil {
 		log.Fatalf("AutoMigrate failed: %v", err)
 	}
 
+	// Department names are unique regardless of case
+	if err := controllers.EnsureDepartmentNameIndex(); err != nil {
+		log.Fatalf("Department name index failed (rename duplicate departments first): %v", err)
+	}
+
+	// Employees from before job history get their hire row
+	if err := controllers.BackfillJobHistory(); err != nil {
+		log.Fatalf("Backfilling job history failed: %v", err)
+	}
+
+	// Attachment storage (local directory or S3-compatible bucket)
+	if err := storage.Init(); err != nil {
+		log.Fatalf("Attachment storage setup failed: %v", err)
+	}
+
+	// Seed default leave types/policies on a fresh database
+	if err := controllers.EnsureDefaultLeaveTypes(); err != nil {
+		log.Fatalf("Seeding leave policies failed: %v", err)
+	}
+
+	// Background jobs (leave accruals, year-end rollover, ...)
+	jobs.Start()
+
 	// Initialize Gin router
 	r := gin.Default()
 	r.Use(config.CorsMiddleware())
@@ -56,6 +105,9 @@ func main() {
 		auth.POST("/auth0-login", controllers.Auth0Login)
 	}
 
+	// Leave calendar subscription (.ics); the token in the URL is the credential
+	r.GET("/api/calendar/:token", controllers.LeaveCalendarFeed)
+
 	// ========================================
 	// PROTECTED ROUTES (Require Authentication)
 	// ========================================
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyKeyTTL is how long a stored response is replayed for a key.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// responseRecorder keeps a copy of what the handler writes.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// requestHash fingerprints a request by method, path and body, so a key
+// cannot replay its response for a different request.
+func requestHash(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to
+// retry: the first request with a key runs normally and its response is
+// stored; a repeat of it by the same user gets that response back without
+// running the handler again. Reusing a key for a different request (method,
+// path or body) is rejected with 422. Must run after AuthRequired.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		userID := c.GetUint("userID")
+		path := c.Request.URL.Path
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := requestHash(c.Request.Method, path, body)
+
+		// forget expired keys so they can be used again
+		config.DB.Where("user_id = ? AND key = ? AND created_at < ?", userID, key, time.Now().Add(-IdempotencyKeyTTL)).
+			Delete(&models.IdempotencyKey{})
+
+		record := models.IdempotencyKey{UserID: userID, Key: key, Method: c.Request.Method, Path: path, RequestHash: hash}
+		res := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if res.Error != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to record idempotency key"})
+			return
+		}
+
+		if res.RowsAffected == 0 {
+			var prev models.IdempotencyKey
+			err := config.DB.Where("user_id = ? AND key = ?", userID, key).First(&prev).Error
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				// removed between the insert and now; treat as in flight
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still being processed"})
+			case err != nil:
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to look up idempotency key"})
+			case prev.RequestHash != hash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			case prev.Status == 0:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still being processed"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(prev.Status, prev.ContentType, prev.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		// server errors (and panics) are not stored so the client can retry with the same key
+		stored := false
+		defer func() {
+			if !stored {
+				config.DB.Delete(&record)
+			}
+		}()
+
+		rec := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+
+		status := rec.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		stored = true
+		config.DB.Model(&record).Updates(map[string]interface{}{
+			"status":       status,
+			"content_type": rec.Header().Get("Content-Type"),
+			"body":         rec.body.Bytes(),
+		})
+	}
+}
+
+// PruneIdempotencyKeys deletes stored responses older than IdempotencyKeyTTL.
+func PruneIdempotencyKeys(now time.Time) error {
+	return config.DB.Where("created_at < ?", now.Add(-IdempotencyKeyTTL)).Delete(&models.IdempotencyKey{}).Error
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// CalendarToken is the secret that lets calendar clients fetch a user's
+// leave feed (.ics) without signing in. Rotating it replaces the token.
+type CalendarToken struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex" json:"user_id"`
+	Token     string    `gorm:"size:64;not null;uniqueIndex" json:"token"`
+	CreatedAt time.Time `json:"created_at"`
+
+	User User `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+}
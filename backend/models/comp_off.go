@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// CompOffRequest is a claim for compensatory leave after working on a weekend
+// or holiday. Once approved its days are credited to the worker's "comp_off"
+// allocation for Year and lapse, if still unused, after ExpiresOn.
+type CompOffRequest struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	WorkedOn    time.Time  `gorm:"type:date;not null" json:"worked_on"`
+	Days        float64    `gorm:"type:numeric(6,2);not null" json:"days"` // 1 or 0.5
+	Reason      string     `json:"reason"`
+	Status      string     `gorm:"size:20;default:pending;index" json:"status"` // pending / approved / rejected
+	DecidedBy   *uint      `json:"decided_by"`
+	DecidedAt   *time.Time `json:"decided_at"`
+	Comment     string     `json:"comment"`
+	Year        int        `json:"year"` // allocation year credited
+	ExpiresOn   *time.Time `gorm:"type:date;index" json:"expires_on"`
+	ExpiredDays float64    `gorm:"type:numeric(6,2)" json:"expired_days"`
+	ExpiredAt   *time.Time `json:"expired_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+
+	User User `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+}
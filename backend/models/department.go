@@ -1,6 +1,16 @@
 package models
 
+import "time"
+
+// Department is a unit of the organisation. Departments nest through
+// ParentID; HeadID is the employee who leads the department and CostCenter
+// the finance code its people are charged to.
 type Department struct {
-	ID   uint   `gorm:"primaryKey"`
-	Name string `gorm:"size:100;not null"`
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Name       string    `gorm:"size:100;not null" json:"name"`
+	ParentID   *uint     `gorm:"index" json:"parent_id"`
+	HeadID     *uint     `json:"head_id"`
+	CostCenter string    `gorm:"size:30" json:"cost_center"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
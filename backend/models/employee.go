@@ -8,6 +8,7 @@ type Employee struct {
 	Designation  string    `gorm:"size:100" json:"designation"`
 	DepartmentID uint      `json:"department_id"`
 	ManagerID    *uint     `json:"manager_id"`
+	PositionID   *uint     `gorm:"index" json:"position_id"`
 	Phone        string    `json:"phone"`
 	Location     string    `json:"location"`
 	CreatedAt    time.Time `json:"created_at"`
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Job actions recorded on EmployeeJob rows.
+const (
+	JobActionHire       = "hire"
+	JobActionPromotion  = "promotion"
+	JobActionTransfer   = "transfer"
+	JobActionReorg      = "reorg"
+	JobActionDataChange = "data_change"
+)
+
+// EmployeeJob is one effective-dated row of an employee's job history: the
+// designation, department, manager and location in force from EffectiveDate.
+// Several changes on the same date are ordered by Sequence. The Employee row
+// mirrors the latest row whose EffectiveDate has been reached; AppliedAt is
+// set once a row has been copied to it.
+type EmployeeJob struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	EmployeeID    uint       `gorm:"not null;uniqueIndex:idx_employee_job_effseq" json:"employee_id"`
+	EffectiveDate time.Time  `gorm:"type:date;not null;uniqueIndex:idx_employee_job_effseq" json:"effective_date"`
+	Sequence      int        `gorm:"not null;uniqueIndex:idx_employee_job_effseq" json:"sequence"`
+	Action        string     `gorm:"size:30;not null" json:"action"`
+	Reason        string     `json:"reason"`
+	Designation   string     `gorm:"size:100" json:"designation"`
+	DepartmentID  uint       `json:"department_id"`
+	ManagerID     *uint      `json:"manager_id"`
+	Location      string     `json:"location"`
+	CreatedBy     *uint      `json:"created_by"`
+	AppliedAt     *time.Time `json:"applied_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+
+	Employee Employee `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+}
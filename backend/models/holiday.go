@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Holiday is a company holiday. An empty Location means the holiday applies
+// to every office; otherwise it only applies to employees whose
+// Employee.Location matches (case-insensitive).
+type Holiday struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"size:100;not null" json:"name"`
+	Date        time.Time `gorm:"type:date;not null;index" json:"date"`
+	Location    string    `gorm:"size:100;index" json:"location"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
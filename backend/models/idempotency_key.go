@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the response to a request sent with an
+// Idempotency-Key header, so a retry of the same request gets the same answer
+// instead of repeating its effect.
+type IdempotencyKey struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_idempotency_user_key" json:"user_id"`
+	Key         string    `gorm:"size:255;not null;uniqueIndex:idx_idempotency_user_key" json:"key"`
+	Method      string    `gorm:"size:10;not null" json:"method"`
+	Path        string    `gorm:"size:255;not null" json:"path"`
+	RequestHash string    `gorm:"size:64" json:"request_hash"` // SHA-256 of method, path and body
+	Status      int       `json:"status"`                      // 0 while the first request is still being handled
+	ContentType string    `gorm:"size:100" json:"content_type"`
+	Body        []byte    `json:"-"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
@@ -8,8 +8,16 @@ type Leave struct {
 	StartDate  time.Time
 	EndDate    time.Time
 	Type       string
+	Portion    string  `gorm:"size:20;default:full"` // full / first_half / second_half / hours
+	Hours      float64 // set when Portion is "hours"
+	Days       float64 `gorm:"type:numeric(6,2)"` // days charged when applied
 	Reason     string
-	Status     string `gorm:"default:pending"` // pending / approved / rejected
+	Status     string `gorm:"default:pending"` // pending / approved / rejected / withdrawn / cancelled
 	ApprovedBy *uint  // Nullable - set when approved/rejected
-	CreatedAt  time.Time
+
+	// approval chain captured when the leave was applied for (see LeaveApprovalRule)
+	ApprovalChain string `gorm:"size:100"`
+	ApprovalStep  int    `gorm:"default:0"` // index of the step awaiting a decision
+
+	CreatedAt time.Time
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LeaveAccrual records one accrual period credited to a LeaveAllocation.
+type LeaveAccrual struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_accrual_period" json:"user_id"`
+	Year        int       `gorm:"not null;uniqueIndex:idx_accrual_period" json:"year"`
+	Type        string    `gorm:"not null;uniqueIndex:idx_accrual_period" json:"type"`
+	PeriodStart time.Time `gorm:"type:date;not null;uniqueIndex:idx_accrual_period" json:"period_start"`
+	Days        float64   `gorm:"type:numeric(6,2);not null" json:"days"`
+	PostedAt    time.Time `json:"posted_at"`
+}
@@ -1,10 +1,10 @@
 package models
 
 type LeaveAllocation struct {
-	ID     uint   `gorm:"primaryKey"`
-	UserID uint   `gorm:"not null;index"`
-	Year   int    `gorm:"not null;index"`
-	Type   string `gorm:"not null"` // e.g. "sick", "casual"
-	Total  int    `gorm:"not null"` // total days allocated for the year
-	Used   int    `gorm:"not null"` // days already used (or blocked by pending)
+	ID     uint    `gorm:"primaryKey"`
+	UserID uint    `gorm:"not null;index;uniqueIndex:idx_allocation_user_year_type"`
+	Year   int     `gorm:"not null;index;uniqueIndex:idx_allocation_user_year_type"`
+	Type   string  `gorm:"not null;uniqueIndex:idx_allocation_user_year_type"` // e.g. "sick", "casual"
+	Total  float64 `gorm:"type:numeric(6,2);not null"`                         // total days allocated for the year
+	Used   float64 `gorm:"type:numeric(6,2);not null"`                         // days already used (or blocked by pending)
 }
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// LeaveApprovalRule decides who must approve a leave. Chain is a comma
+// separated list of approver steps ("manager", "hr"), e.g. "manager,hr".
+// The rule with the highest MinDays that a request reaches applies; a rule
+// for the specific LeaveType beats one with an empty LeaveType (any type).
+type LeaveApprovalRule struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	LeaveType string    `gorm:"size:50;index" json:"leave_type"`
+	MinDays   float64   `gorm:"type:numeric(6,2);not null;default:0" json:"min_days"`
+	Chain     string    `gorm:"size:100;not null" json:"chain"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LeaveApprovalDelegation lets DelegateID act on leave approvals that are
+// waiting for DelegatorID between StartDate and EndDate (inclusive).
+type LeaveApprovalDelegation struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	DelegatorID uint      `gorm:"not null;index" json:"delegator_id"`
+	DelegateID  uint      `gorm:"not null;index" json:"delegate_id"`
+	StartDate   time.Time `gorm:"type:date;not null" json:"start_date"`
+	EndDate     time.Time `gorm:"type:date;not null" json:"end_date"`
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// LeaveApproval is one decision taken on a leave's approval chain.
+type LeaveApproval struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	LeaveID    uint      `gorm:"not null;index" json:"leave_id"`
+	Step       int       `gorm:"not null" json:"step"` // 0-based position in the chain
+	StepRole   string    `gorm:"size:20;not null" json:"step_role"`
+	ActorID    uint      `gorm:"not null" json:"actor_id"`         // 0 when the scheduler auto-approved
+	OnBehalfOf *uint     `json:"on_behalf_of"`                     // set when a delegate acted
+	Decision   string    `gorm:"size:20;not null" json:"decision"` // approved / rejected
+	Comment    string    `json:"comment"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	Leave Leave `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+}
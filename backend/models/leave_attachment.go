@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// LeaveAttachment is a supporting document (e.g. a medical certificate)
+// uploaded for a leave. The file itself lives in the attachment storage
+// under StorageKey.
+type LeaveAttachment struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	LeaveID     uint      `gorm:"not null;index" json:"leave_id"`
+	UploadedBy  uint      `gorm:"not null" json:"uploaded_by"`
+	FileName    string    `gorm:"size:255;not null" json:"file_name"`
+	ContentType string    `gorm:"size:100" json:"content_type"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `gorm:"size:255;not null" json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	Leave Leave `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+}
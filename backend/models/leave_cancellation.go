@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// LeaveCancellation is a request to cancel all or the tail end of an approved
+// leave (e.g. returning early). The days from CancelFrom to the leave's end
+// date are given back once the employee's manager acknowledges it.
+type LeaveCancellation struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	LeaveID        uint       `gorm:"not null;index" json:"leave_id"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"` // leave owner
+	RequestedBy    uint       `gorm:"not null" json:"requested_by"`
+	CancelFrom     time.Time  `gorm:"type:date;not null" json:"cancel_from"`
+	Full           bool       `gorm:"not null" json:"full"`
+	Days           float64    `gorm:"type:numeric(6,2)" json:"days"` // days restored
+	Reason         string     `json:"reason"`
+	Status         string     `gorm:"size:20;default:pending;index" json:"status"` // pending / acknowledged / declined
+	AcknowledgedBy *uint      `json:"acknowledged_by"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	Comment        string     `json:"comment"`
+	CreatedAt      time.Time  `json:"created_at"`
+
+	Leave Leave `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+}
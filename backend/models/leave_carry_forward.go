@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// LeaveCarryForward records one year-end rollover of an allocation: how much
+// of the unused balance moved into ToYear, how much lapsed, and when (and how
+// much of) the carried days expired.
+type LeaveCarryForward struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;uniqueIndex:idx_carry_forward" json:"user_id"`
+	Type        string     `gorm:"not null;uniqueIndex:idx_carry_forward" json:"type"`
+	FromYear    int        `gorm:"not null;uniqueIndex:idx_carry_forward" json:"from_year"`
+	ToYear      int        `gorm:"not null;index" json:"to_year"`
+	Unused      float64    `gorm:"type:numeric(6,2);not null" json:"unused"`
+	Carried     float64    `gorm:"type:numeric(6,2);not null" json:"carried"`
+	Lapsed      float64    `gorm:"type:numeric(6,2);not null" json:"lapsed"`
+	ExpiresOn   *time.Time `gorm:"type:date" json:"expires_on"`
+	ExpiredDays float64    `gorm:"type:numeric(6,2)" json:"expired_days"`
+	ExpiredAt   *time.Time `json:"expired_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
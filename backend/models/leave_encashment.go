@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// LeaveEncashment is a request to be paid for unused days of a leave
+// allocation instead of taking them. Approved requests are deducted from the
+// allocation and listed in the payroll export.
+type LeaveEncashment struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Year       int        `gorm:"not null" json:"year"`
+	Type       string     `gorm:"size:40;not null" json:"type"`
+	Days       float64    `gorm:"type:numeric(6,2);not null" json:"days"`
+	Reason     string     `json:"reason"`
+	Status     string     `gorm:"size:20;default:pending;index" json:"status"` // pending / approved / rejected
+	DecidedBy  *uint      `json:"decided_by"`
+	DecidedAt  *time.Time `json:"decided_at"`
+	Comment    string     `json:"comment"`
+	ExportedAt *time.Time `json:"exported_at"` // first included in a payroll export
+	CreatedAt  time.Time  `json:"created_at"`
+
+	User User `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+}
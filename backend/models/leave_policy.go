@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// LeaveType is a kind of leave employees can request. Code is the value
+// stored in Leave.Type and LeaveAllocation.Type (e.g. "sick", "casual").
+type LeaveType struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Code      string    `gorm:"size:40;uniqueIndex;not null" json:"code"`
+	Name      string    `gorm:"size:100;not null" json:"name"`
+	Active    bool      `gorm:"not null" json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Policies []LeavePolicy `json:"policies,omitempty"`
+}
+
+// LeavePolicy holds the rules for one leave type. Role, DepartmentID and
+// Location narrow who the policy applies to (empty matches everyone); when
+// several policies match an employee the most specific one wins.
+type LeavePolicy struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	LeaveTypeID  uint   `gorm:"not null;index" json:"leave_type_id"`
+	Role         string `gorm:"size:20" json:"role"`
+	DepartmentID *uint  `json:"department_id"`
+	Location     string `gorm:"size:100" json:"location"`
+
+	AnnualEntitlement  int  `gorm:"not null" json:"annual_entitlement"`
+	MaxConsecutiveDays int  `json:"max_consecutive_days"` // 0 = no limit
+	MinNoticeDays      int  `json:"min_notice_days"`
+	CountWeekends      bool `json:"count_weekends"`
+	CountHolidays      bool `json:"count_holidays"`
+	SandwichRule       bool `json:"sandwich_rule"` // weekends/holidays between two leave days are charged too
+	RequiresDocument   bool `json:"requires_document"`
+	DocumentAfterDays  int  `json:"document_after_days"` // document needed above this many days (0 = always)
+
+	// AccrualFrequency is how the entitlement is credited: "annual" (all up
+	// front), "quarterly" or "monthly". The first year is pro-rated from the
+	// employee's joining date either way.
+	AccrualFrequency string `gorm:"size:20;default:annual" json:"accrual_frequency"`
+
+	// CarryForwardCap is how many unused days roll into the next year (0 =
+	// none); the rest lapse. Carried days expire at the end of month
+	// CarryForwardExpiryMonths of the new year (0 = they last all year).
+	CarryForwardCap          int `json:"carry_forward_cap"`
+	CarryForwardExpiryMonths int `json:"carry_forward_expiry_months"`
+
+	// MaxEncashableDays is how many unused days an employee may be paid out
+	// per year (0 = the type cannot be encashed).
+	MaxEncashableDays int `json:"max_encashable_days"`
+
+	CreatedAt time.Time `json:"created_at"`
+
+	LeaveType LeaveType `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Leave ledger entry kinds.
+const (
+	LeaveTxnGrant        = "grant"         // up-front annual entitlement
+	LeaveTxnAccrual      = "accrual"       // periodic accrual credit
+	LeaveTxnCarryForward = "carry_forward" // balance moved between years at rollover
+	LeaveTxnAdjustment   = "adjustment"    // manual HR correction (either sign)
+	LeaveTxnExpiry       = "expiry"        // lapsed or expired days
+	LeaveTxnEncashment   = "encashment"    // unused days paid out
+	LeaveTxnDebit        = "debit"         // days blocked by a leave request
+	LeaveTxnCredit       = "credit"        // days returned by a rejected/withdrawn leave
+)
+
+// LeaveTransaction is an immutable entry in the leave ledger. Days is signed
+// from the balance's point of view (debits, expiries and encashments are negative).
+// LeaveAllocation.Total is the sum of the entitlement kinds and
+// LeaveAllocation.Used the negated sum of debits and credits, so the
+// allocation row can always be rebuilt from the ledger.
+type LeaveTransaction struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index:idx_leave_txn_alloc" json:"user_id"`
+	Year      int       `gorm:"not null;index:idx_leave_txn_alloc" json:"year"`
+	Type      string    `gorm:"not null;index:idx_leave_txn_alloc" json:"type"`
+	Kind      string    `gorm:"size:20;not null" json:"kind"`
+	Days      float64   `gorm:"type:numeric(6,2);not null" json:"days"`
+	LeaveID   *uint     `gorm:"index" json:"leave_id"`
+	Reason    string    `json:"reason"`
+	CreatedBy *uint     `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AffectsUsage reports whether the entry counts towards Used rather than Total.
+func (t LeaveTransaction) AffectsUsage() bool {
+	return t.Kind == LeaveTxnDebit || t.Kind == LeaveTxnCredit
+}
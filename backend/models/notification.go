@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Notification is an in-app message for a user (leave reminders, escalations, ...).
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Kind      string     `gorm:"size:50;not null" json:"kind"`
+	Title     string     `gorm:"size:200;not null" json:"title"`
+	Message   string     `json:"message"`
+	LeaveID   *uint      `json:"leave_id"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	User User `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+}
+
+// LeaveReminder records that a reminder or escalation for a step of a
+// pending leave was sent to a recipient, so each is only sent once.
+type LeaveReminder struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	LeaveID     uint      `gorm:"not null;uniqueIndex:idx_leave_reminder" json:"leave_id"`
+	Step        int       `gorm:"not null;uniqueIndex:idx_leave_reminder" json:"step"`
+	Kind        string    `gorm:"size:20;not null;uniqueIndex:idx_leave_reminder" json:"kind"` // reminder / escalation
+	RecipientID uint      `gorm:"not null;uniqueIndex:idx_leave_reminder" json:"recipient_id"`
+	SentAt      time.Time `json:"sent_at"`
+
+	Leave Leave `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+}
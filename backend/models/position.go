@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Position statuses. A position is filled while an employee holds it; HR
+// freezes open positions that should not be recruited for.
+const (
+	PositionOpen   = "open"
+	PositionFilled = "filled"
+	PositionFrozen = "frozen"
+)
+
+// Position is a budgeted seat in the organisation, which exists whether or
+// not anyone fills it. ReportsToID is the position it reports to, so the
+// planned hierarchy survives people moving. VacantSince is set while the
+// position has no incumbent.
+type Position struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Title        string     `gorm:"size:100;not null" json:"title"`
+	DepartmentID uint       `gorm:"index" json:"department_id"`
+	ReportsToID  *uint      `gorm:"index" json:"reports_to_id"`
+	FTE          float64    `gorm:"not null;default:1" json:"fte"`
+	Status       string     `gorm:"size:20;not null;default:open" json:"status"`
+	VacantSince  *time.Time `json:"vacant_since"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
@@ -10,22 +10,31 @@ import (
 func SetupRoutes(r *gin.Engine) {
 	// Protected API routes
 	api := r.Group("/api")
-	api.Use(middleware.AuthRequired())
+	api.Use(middleware.AuthRequired(), middleware.Idempotency())
 	{
 		// Employees
 		api.GET("/employees", controllers.ListEmployees)
 		api.GET("/employees/:id", controllers.GetEmployee)
+		api.GET("/employees/:id/chain", controllers.GetManagementChain)
+		api.GET("/employees/:id/history", controllers.GetEmployeeHistory)
 		api.POST("/employees", controllers.CreateEmployee)
+		api.POST("/employees/import", controllers.ImportEmployees)
 		api.PUT("/employees/:id", controllers.UpdateEmployee)
 		api.DELETE("/employees/:id", controllers.DeleteEmployee)
 		api.GET("/my-team", controllers.ListMyTeam)
 
 		api.GET("/users/by-email/:email", controllers.GetUserByEmail)
 		api.DELETE("/users/:id", controllers.DeleteUser)
+		api.POST("/users/:id/reset-password", controllers.ResetUserPassword)
+		api.PUT("/me/password", controllers.ChangeMyPassword)
 
 		// Manager team
 		api.GET("/managers/:managerId/team", controllers.ListTeam)
 
+		// Org chart
+		api.GET("/org/tree", controllers.GetOrgTree)
+		api.GET("/org/span-of-control", controllers.GetSpanOfControl)
+
 	}
 
 	leaves := api.Group("/leaves")
@@ -33,10 +42,109 @@ func SetupRoutes(r *gin.Engine) {
 		leaves.POST("", controllers.CreateLeave)
 		leaves.GET("/my", controllers.ListMyLeaves)
 		leaves.GET("/team", controllers.ListTeamLeaves)
+		leaves.GET("/calendar", controllers.GetLeaveCalendar)
+		leaves.GET("/calendar/feed", controllers.GetCalendarFeed)
+		leaves.POST("/calendar/feed/rotate", controllers.RotateCalendarFeed)
 		leaves.GET("/balance", controllers.GetMyLeaveBalance)
+		leaves.GET("/balance/forecast", controllers.GetLeaveBalanceForecast)
+		leaves.GET("/accruals", controllers.GetAccrualSchedule)
+		leaves.POST("/rollover", controllers.RolloverLeaves)
+		leaves.GET("/ledger", controllers.ListLeaveLedger)
+		leaves.POST("/adjustments", controllers.CreateLeaveAdjustment)
+		leaves.GET("/approvals/pending", controllers.ListPendingApprovals)
+		leaves.POST("/bulk-decision", controllers.BulkLeaveDecision)
+		leaves.GET("/delegations", controllers.ListDelegations)
+		leaves.POST("/delegations", controllers.CreateDelegation)
+		leaves.DELETE("/delegations/:id", controllers.DeleteDelegation)
+		leaves.GET("/cancellations", controllers.ListCancellations)
+		leaves.PUT("/cancellations/:id/acknowledge", controllers.AcknowledgeCancellation)
+		leaves.PUT("/cancellations/:id/decline", controllers.DeclineCancellation)
+		leaves.GET("/comp-off", controllers.ListCompOffRequests)
+		leaves.POST("/comp-off", controllers.CreateCompOffRequest)
+		leaves.PUT("/comp-off/:id/approve", controllers.ApproveCompOffRequest)
+		leaves.PUT("/comp-off/:id/reject", controllers.RejectCompOffRequest)
+		leaves.GET("/encashments", controllers.ListEncashments)
+		leaves.POST("/encashments", controllers.CreateEncashment)
+		leaves.GET("/encashments/export", controllers.ExportEncashments)
+		leaves.POST("/encashments/export", controllers.ExportEncashmentsToPayroll)
+		leaves.PUT("/encashments/:id/approve", controllers.ApproveEncashment)
+		leaves.PUT("/encashments/:id/reject", controllers.RejectEncashment)
+		leaves.GET("/reports/utilisation", controllers.LeaveUtilisationReport)
+		leaves.GET("/reports/absenteeism", controllers.AbsenteeismReport)
+		leaves.GET("/reports/liability", controllers.LeaveLiabilityReport)
+		leaves.GET("/attachments/:id", controllers.DownloadLeaveAttachment)
+		leaves.DELETE("/attachments/:id", controllers.DeleteLeaveAttachment)
+		leaves.GET("/:id/approvals", controllers.GetLeaveApprovals)
+		leaves.GET("/:id/attachments", controllers.ListLeaveAttachments)
+		leaves.POST("/:id/attachments", controllers.UploadLeaveAttachments)
 		leaves.PUT("/:id/approve", controllers.ApproveLeave)
 		leaves.PUT("/:id/reject", controllers.RejectLeave)
 		leaves.PUT("/:id/withdraw", controllers.WithdrawLeave)
+		leaves.POST("/:id/cancel", controllers.CancelApprovedLeave)
+	}
+
+	// Leave types and policies
+	leaveTypes := api.Group("/leave-types")
+	{
+		leaveTypes.GET("", controllers.ListLeaveTypes)
+		leaveTypes.POST("", controllers.CreateLeaveType)
+		leaveTypes.PUT("/:id", controllers.UpdateLeaveType)
+		leaveTypes.POST("/:id/policies", controllers.CreateLeavePolicy)
+	}
+	leavePolicies := api.Group("/leave-policies")
+	{
+		leavePolicies.PUT("/:id", controllers.UpdateLeavePolicy)
+		leavePolicies.DELETE("/:id", controllers.DeleteLeavePolicy)
+	}
+
+	// Leave approval chains
+	approvalRules := api.Group("/leave-approval-rules")
+	{
+		approvalRules.GET("", controllers.ListApprovalRules)
+		approvalRules.POST("", controllers.CreateApprovalRule)
+		approvalRules.PUT("/:id", controllers.UpdateApprovalRule)
+		approvalRules.DELETE("/:id", controllers.DeleteApprovalRule)
+	}
+
+	// In-app notifications
+	notifications := api.Group("/notifications")
+	{
+		notifications.GET("", controllers.ListNotifications)
+		notifications.PUT("/read-all", controllers.MarkAllNotificationsRead)
+		notifications.PUT("/:id/read", controllers.MarkNotificationRead)
+	}
+
+	// Departments
+	departments := api.Group("/departments")
+	{
+		departments.GET("", controllers.ListDepartments)
+		departments.POST("", controllers.CreateDepartment)
+		departments.GET("/:id", controllers.GetDepartment)
+		departments.PUT("/:id", controllers.UpdateDepartment)
+		departments.DELETE("/:id", controllers.DeleteDepartment)
+	}
+
+	// Positions
+	positions := api.Group("/positions")
+	{
+		positions.GET("", controllers.ListPositions)
+		positions.POST("", controllers.CreatePosition)
+		positions.GET("/open", controllers.ListOpenPositions)
+		positions.GET("/vacancies", controllers.PositionVacancyReport)
+		positions.GET("/:id", controllers.GetPosition)
+		positions.PUT("/:id", controllers.UpdatePosition)
+		positions.DELETE("/:id", controllers.DeletePosition)
+		positions.PUT("/:id/assign", controllers.AssignPosition)
+		positions.PUT("/:id/vacate", controllers.VacatePosition)
+	}
+
+	// Holiday calendar
+	holidays := api.Group("/holidays")
+	{
+		holidays.GET("", controllers.ListHolidays)
+		holidays.POST("", controllers.CreateHoliday)
+		holidays.PUT("/:id", controllers.UpdateHoliday)
+		holidays.DELETE("/:id", controllers.DeleteHoliday)
 	}
 
 	// PMS routes
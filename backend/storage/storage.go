@@ -0,0 +1,46 @@
+// Package storage keeps uploaded files (leave attachments) outside the
+// database. The backend is chosen with ATTACHMENT_STORAGE: "local" (default)
+// writes under ATTACHMENT_DIR, "s3" talks to any S3-compatible service.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrNotFound is returned by Open when the key does not exist.
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage stores opaque blobs under slash-separated keys.
+type Storage interface {
+	Put(key string, data []byte, contentType string) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// Default is the backend configured by Init.
+var Default Storage
+
+// Init configures Default from the environment.
+func Init() error {
+	switch backend := strings.ToLower(strings.TrimSpace(os.Getenv("ATTACHMENT_STORAGE"))); backend {
+	case "", "local":
+		dir := os.Getenv("ATTACHMENT_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		Default = NewLocal(dir)
+	case "s3":
+		s, err := NewS3FromEnv()
+		if err != nil {
+			return err
+		}
+		Default = s
+	default:
+		return fmt.Errorf("storage: unknown ATTACHMENT_STORAGE %q", backend)
+	}
+	return nil
+}
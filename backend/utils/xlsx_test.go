@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestXLSXColumn(t *testing.T) {
+	tests := []struct {
+		index int
+		name  string
+	}{
+		{0, "A"},
+		{1, "B"},
+		{25, "Z"},
+		{26, "AA"},
+		{27, "AB"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+	for _, tt := range tests {
+		if got := xlsxColumn(tt.index); got != tt.name {
+			t.Errorf("xlsxColumn(%d) = %q, want %q", tt.index, got, tt.name)
+		}
+		if got := xlsxColumnIndex(tt.name + "7"); got != tt.index {
+			t.Errorf("xlsxColumnIndex(%q) = %d, want %d", tt.name+"7", got, tt.index)
+		}
+	}
+}
+
+func TestXLSXRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []string
+		rows   [][]any
+		want   [][]string
+	}{
+		{
+			name:   "header only",
+			header: []string{"name", "email"},
+			want:   [][]string{{"name", "email"}},
+		},
+		{
+			name:   "text and numbers",
+			header: []string{"name", "days", "year", "id"},
+			rows: [][]any{
+				{"Asha", 2.5, 2025, uint(7)},
+				{"Ben", float64(0), int64(2024), uint(12)},
+			},
+			want: [][]string{
+				{"name", "days", "year", "id"},
+				{"Asha", "2.5", "2025", "7"},
+				{"Ben", "0", "2024", "12"},
+			},
+		},
+		{
+			name:   "escaping, blanks and dates",
+			header: []string{"note", "empty", "date"},
+			rows: [][]any{
+				{`<b>"R&D"</b>`, nil, time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)},
+				{"  padded  ", "", (*time.Time)(nil)},
+			},
+			want: [][]string{
+				{"note", "empty", "date"},
+				{`<b>"R&D"</b>`, "", "2025-03-14"},
+				{"  padded  ", "", ""},
+			},
+		},
+		{
+			name:   "past column Z",
+			header: make([]string, 28),
+			want:   [][]string{make([]string, 28)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteXLSX(&buf, "Report", tt.header, tt.rows); err != nil {
+				t.Fatalf("WriteXLSX: %v", err)
+			}
+			got, err := ReadXLSX(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("ReadXLSX: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("round trip = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadXLSXRejectsNonZip(t *testing.T) {
+	data := []byte("name,email\nAsha,asha@example.com\n")
+	if _, err := ReadXLSX(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Fatal("ReadXLSX accepted a CSV file")
+	}
+}
+
+func TestXLSXWorkbookSheetName(t *testing.T) {
+	tests := []struct {
+		sheet string
+		want  string
+	}{
+		{"Leave liability", `name="Leave liability"`},
+		{"", `name="Sheet1"`},
+		{"a/b:c", `name="a_b_c"`},
+		{"A sheet name that is far too long to fit", `name="A sheet name that is far too lo"`},
+	}
+	for _, tt := range tests {
+		if got := xlsxWorkbook(tt.sheet); !bytes.Contains([]byte(got), []byte(tt.want)) {
+			t.Errorf("xlsxWorkbook(%q) does not contain %s:\n%s", tt.sheet, tt.want, got)
+		}
+	}
+}
+
+func TestXLSXDate(t *testing.T) {
+	tests := []struct {
+		serial float64
+		want   time.Time
+	}{
+		{1, time.Date(1899, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{45658, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{45658.75, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := XLSXDate(tt.serial); !got.Equal(tt.want) {
+			t.Errorf("XLSXDate(%v) = %v, want %v", tt.serial, got, tt.want)
+		}
+	}
+}